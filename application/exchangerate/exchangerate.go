@@ -1,59 +1,336 @@
 package exchangerate
 
 import (
+	"context"
 	"encoding/json"
 	"errors"
+	"fmt"
 	"io/ioutil"
+	"job/domain/currency"
+	"job/domain/models"
+	"job/domain/money"
 	"job/presentation/core/jsonint"
+	"job/presentation/core/mytime"
+	"job/presentation/core/tracing"
 	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
 
 	"github.com/shopspring/decimal"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/trace"
 )
 
+const providerURL = "https://api.exchangeratesapi.io"
+
 type SavedRates jsonint.AllRatesJSON
 
-var rates SavedRates
+var tracer = otel.Tracer("job/application/exchangerate")
+
+var (
+	rates        SavedRates
+	fetchedAt    time.Time
+	providerBase = money.DefaultCurrency
+	ratesMu      sync.RWMutex
+
+	ratesTTL      atomic.Int64
+	fetchFailures atomic.Uint64
+)
 
-func (rates *SavedRates) getCurrencyRates() (*map[string]float64, error) {
-	url := "https://api.exchangeratesapi.io/latest?base=RUB"
+// SetRatesTTL sets how long downloaded rates are used before they are
+// downloaded again. Zero keeps them for the life of the process.
+func SetRatesTTL(ttl time.Duration) {
+	ratesTTL.Store(int64(ttl))
+}
+
+// SetProviderBase sets the currency the provider quotes rates from. It may
+// differ from the ledger base currency: other pairs are crossed through it.
+func SetProviderBase(code string) error {
+	iso, err := currency.Get(code)
+	if err != nil {
+		return err
+	}
+
+	ratesMu.Lock()
+	defer ratesMu.Unlock()
+	if providerBase != iso.Code {
+		providerBase = iso.Code
+		rates = SavedRates{}
+		fetchedAt = time.Time{}
+	}
+	return nil
+}
+
+// getCurrencyRates downloads rates from base for date, or the latest ones
+// when date is empty.
+func getCurrencyRates(ctx context.Context, base, date string) (*SavedRates, error) {
+	path := "latest"
+	if date != "" {
+		path = date
+	}
+	url := fmt.Sprintf("%s/%s?base=%s", providerURL, path, base)
 
-	resp, err := http.Get(url)
+	saved, err := fetchRates(ctx, url)
 	if err != nil {
+		fetchFailures.Add(1)
 		return nil, err
 	}
+	if saved.Base == "" {
+		saved.Base = base
+	}
+
+	return saved, nil
+}
+
+// fetchRates downloads rates in a client span, passing the trace context on
+// to the provider.
+func fetchRates(ctx context.Context, url string) (*SavedRates, error) {
+	ctx, span := tracer.Start(ctx, "exchangerate.fetch", trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(attribute.String("http.request.method", http.MethodGet), attribute.String("url.full", url)))
+	defer span.End()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return nil, tracing.Fail(span, err)
+	}
+	otel.GetTextMapPropagator().Inject(ctx, propagation.HeaderCarrier(req.Header))
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return nil, tracing.Fail(span, err)
+	}
 	defer resp.Body.Close()
 
+	span.SetAttributes(attribute.Int("http.response.status_code", resp.StatusCode))
+	if resp.StatusCode != http.StatusOK {
+		return nil, tracing.Fail(span, fmt.Errorf("rate provider answered %s", resp.Status))
+	}
+
 	body, err := ioutil.ReadAll(resp.Body)
 	if err != nil {
-		return nil, err
+		return nil, tracing.Fail(span, err)
 	}
-	if err := json.Unmarshal(body, &rates); err != nil {
+	var saved SavedRates
+	if err := json.Unmarshal(body, &saved); err != nil {
+		return nil, tracing.Fail(span, err)
+	}
+	return &saved, nil
+}
+
+// RatesAge tells how long ago the cached latest rates were downloaded, and
+// false if they haven't been yet.
+func RatesAge() (time.Duration, bool) {
+	ratesMu.RLock()
+	defer ratesMu.RUnlock()
+	if fetchedAt.IsZero() {
+		return 0, false
+	}
+	return time.Since(fetchedAt), true
+}
+
+// FetchFailures counts the failed downloads from the rate provider.
+func FetchFailures() uint64 {
+	return fetchFailures.Load()
+}
+
+// cachedRates returns the latest rates from the provider's base currency and
+// when they were downloaded.
+func cachedRates(ctx context.Context) (SavedRates, time.Time, error) {
+	ratesMu.RLock()
+	saved, at, base := rates, fetchedAt, providerBase
+	ratesMu.RUnlock()
+	ttl := time.Duration(ratesTTL.Load())
+	if saved.Rates != nil && (ttl <= 0 || time.Since(at) < ttl) {
+		return saved, at, nil
+	}
+
+	fetched, err := getCurrencyRates(ctx, base, "")
+	if err != nil {
+		return SavedRates{}, time.Time{}, err
+	}
+	at = time.Now()
+
+	ratesMu.Lock()
+	rates, fetchedAt = *fetched, at
+	ratesMu.Unlock()
+	return *fetched, at, nil
+}
+
+// rateOf is the price of one unit of saved.Base in code.
+func (saved SavedRates) rateOf(code string) (decimal.Decimal, error) {
+	if code == saved.Base {
+		return decimal.NewFromInt(1), nil
+	}
+	current, ok := saved.Rates[code]
+	if !ok || current <= 0 {
+		return decimal.Zero, errors.New("currency doesn't exist")
+	}
+	return decimal.NewFromFloatWithExponent(current, -6), nil
+}
+
+// cross is the price of one unit of from in to, crossed through saved.Base.
+func (saved SavedRates) cross(from, to string) (decimal.Decimal, error) {
+	fromRate, err := saved.rateOf(from)
+	if err != nil {
+		return decimal.Zero, err
+	}
+	toRate, err := saved.rateOf(to)
+	if err != nil {
+		return decimal.Zero, err
+	}
+	return toRate.DivRound(fromRate, 12), nil
+}
+
+// table re-expresses saved from base, skipping base itself.
+func (saved SavedRates) table(base string) (map[string]decimal.Decimal, error) {
+	if _, err := saved.rateOf(base); err != nil {
 		return nil, err
 	}
 
-	return &rates.Rates, nil
+	table := make(map[string]decimal.Decimal, len(saved.Rates)+1)
+	for code := range saved.Rates {
+		if rate, err := saved.cross(base, code); err == nil && code != base {
+			table[code] = rate
+		}
+	}
+	if base != saved.Base {
+		rate, err := saved.cross(base, saved.Base)
+		if err != nil {
+			return nil, err
+		}
+		table[saved.Base] = rate
+	}
+	return table, nil
 }
 
-func ExchangeCurrency(amount *decimal.Decimal, currency string) (*decimal.Decimal, error) {
-	if rates.Rates == nil {
-		_, err := rates.getCurrencyRates()
+// Rates returns the cached rates from the ledger base currency, downloading
+// them on first use.
+func Rates(ctx context.Context) (map[string]decimal.Decimal, error) {
+	saved, _, err := cachedRates(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return saved.table(money.BaseCurrency())
+}
+
+// GetRates returns the rates from base on asOf (a 2006-01-02 date), or the
+// latest ones when asOf is empty. Active overrides apply to the latest rates only.
+func GetRates(ctx context.Context, base, asOf string) (*models.RateTable, error) {
+	base = strings.ToUpper(base)
+
+	var saved SavedRates
+	if asOf == "" {
+		cached, _, err := cachedRates(ctx)
 		if err != nil {
 			return nil, err
 		}
+		saved = cached
+	} else {
+		ratesMu.RLock()
+		from := providerBase
+		ratesMu.RUnlock()
+		fetched, err := getCurrencyRates(ctx, from, asOf)
+		if err != nil {
+			return nil, err
+		}
+		saved = *fetched
 	}
 
-	current, ok := rates.Rates[currency]
-	if !ok {
-		err := errors.New("currency doesn't exist")
+	crossed, err := saved.table(base)
+	if err != nil {
 		return nil, err
 	}
-	decimalCurrent := decimal.NewFromFloatWithExponent(current, -6)
-	amountInCurrency := amount.Mul(decimalCurrent)
-	roundedAmount := amountInCurrency.RoundCash(10)
+	table := &models.RateTable{
+		Base:       base,
+		Date:       saved.Date,
+		Rates:      crossed,
+		Overridden: make([]string, 0),
+	}
+
+	if asOf == "" {
+		for code := range table.Rates {
+			if rate, _, ok := overrideRate(ctx, base, code, time.Now()); ok {
+				table.Rates[code] = rate
+				table.Overridden = append(table.Overridden, code)
+			}
+		}
+		sort.Strings(table.Overridden)
+	}
+	return table, nil
+}
 
-	return &roundedAmount, nil
+// ExchangeCurrency converts amount into currency at the market rate less the
+// configured spread, rounded by the rules configured for currency.
+func ExchangeCurrency(ctx context.Context, amount money.Money, currency string) (*models.Conversion, error) {
+	currency = strings.ToUpper(currency)
+	conversion, err := marketRate(ctx, amount.Currency, currency)
+	if err != nil {
+		return nil, err
+	}
+
+	policy := policy.Load()
+	spread := policy.SellSpread
+	if currency == money.BaseCurrency() {
+		spread = policy.BuySpread
+	}
+	rate := conversion.MarketRate.Mul(decimal.NewFromInt(1).Sub(spread))
+
+	rounding := policy.roundingFor(currency)
+	converted, err := money.RoundWith(amount.Amount.Mul(rate), currency, rounding)
+	if err != nil {
+		return nil, err
+	}
+
+	conversion.Amount = converted
+	conversion.Spread = spread
+	conversion.Rate = rate
+	conversion.Rounding = rounding.String()
+	return conversion, nil
+}
+
+// ExchangeCurrencies converts amount into each of currencies in turn,
+// skipping the currency amount is already in.
+func ExchangeCurrencies(ctx context.Context, amount money.Money, currencies []string) ([]models.Conversion, error) {
+	conversions := make([]models.Conversion, 0, len(currencies))
+	for _, currency := range currencies {
+		if strings.EqualFold(currency, amount.Currency) {
+			continue
+		}
+		conversion, err := ExchangeCurrency(ctx, amount, currency)
+		if err != nil {
+			return nil, err
+		}
+		conversions = append(conversions, *conversion)
+	}
+	return conversions, nil
+}
+
+// marketRate is the price of one unit of from in to. A manual override for
+// the pair wins, even while the provider is down; otherwise the pair is
+// crossed through the provider's base currency. Only the rate fields of the
+// returned conversion are filled.
+func marketRate(ctx context.Context, from, to string) (*models.Conversion, error) {
+	if rate, at, ok := overrideRate(ctx, from, to, time.Now()); ok {
+		return &models.Conversion{MarketRate: rate, Override: true, RatedAt: at}, nil
+	}
+
+	saved, at, err := cachedRates(ctx)
+	if err != nil {
+		return nil, err
+	}
+	rate, err := saved.cross(from, to)
+	if err != nil {
+		return nil, err
+	}
+	return &models.Conversion{MarketRate: rate, RatedAt: &mytime.MyTime{Time: &at}}, nil
 }
 
 func Get() *map[string]float64 {
+	ratesMu.RLock()
+	defer ratesMu.RUnlock()
 	return &rates.Rates
 }
@@ -0,0 +1,53 @@
+package exchangerate
+
+import (
+	"context"
+	"log"
+	"testing"
+
+	"job/domain/money"
+)
+
+func Test_ExchangeCurrency_ShouldReturn_SuccessResultCrossRate(t *testing.T) {
+	ratesMu.Lock()
+	rates = SavedRates{Base: "EUR", Date: "2020-09-28", Rates: map[string]float64{"KZT": 500, "USD": 1.25}}
+	providerBase = "EUR"
+	ratesMu.Unlock()
+	defer func() {
+		ratesMu.Lock()
+		rates = SavedRates{}
+		providerBase = money.DefaultCurrency
+		ratesMu.Unlock()
+	}()
+
+	if err := money.SetBaseCurrency("KZT"); err != nil {
+		t.Fatal(err)
+	}
+	defer money.SetBaseCurrency(money.DefaultCurrency)
+
+	amount, err := money.Parse("1000", money.BaseCurrency())
+	if err != nil {
+		t.Fatal(err)
+	}
+	conversion, err := ExchangeCurrency(context.Background(), amount, "usd")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if expected := "2.50 USD"; conversion.Amount.String() != expected {
+		log.Printf("Expected %s, but got %s\n", expected, conversion.Amount)
+		t.Fatal(conversion.Amount)
+	}
+
+	table, err := GetRates(context.Background(), "KZT", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if expected := "0.002"; table.Rates["EUR"].String() != expected {
+		log.Printf("Expected %s, but got %s\n", expected, table.Rates["EUR"])
+		t.Fatal(table.Rates["EUR"])
+	}
+	if _, ok := table.Rates["KZT"]; ok {
+		log.Println("Expected no rate for the base currency itself")
+		t.Fatal(table.Rates)
+	}
+}
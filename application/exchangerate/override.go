@@ -0,0 +1,107 @@
+package exchangerate
+
+import (
+	"context"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"job/domain/models"
+	"job/presentation/core/mytime"
+
+	"github.com/shopspring/decimal"
+)
+
+// OverrideLoader reads the overrides active at now from the store every
+// instance shares.
+type OverrideLoader func(ctx context.Context, now time.Time) ([]models.RateOverride, error)
+
+// overridesRefresh is how long the cached overrides are used before they are
+// loaded again, and so how long other instances may take to see a change.
+const overridesRefresh = 5 * time.Second
+
+var overrides = struct {
+	sync.RWMutex
+	pairs    map[string]models.RateOverride
+	load     OverrideLoader
+	loadedAt time.Time
+	loading  atomic.Bool
+}{pairs: map[string]models.RateOverride{}}
+
+func pairKey(base, currency string) string {
+	return strings.ToUpper(base) + "/" + strings.ToUpper(currency)
+}
+
+// SetOverrideLoader makes the overrides come from load. Without a loader
+// they live in this process only.
+func SetOverrideLoader(load OverrideLoader) {
+	overrides.Lock()
+	defer overrides.Unlock()
+	overrides.load = load
+	overrides.loadedAt = time.Time{}
+}
+
+// SetOverride pins the rate of a currency pair in this process until the
+// override expires or the overrides are loaded again, replacing any earlier
+// override of the same pair. Save it to the store first for other instances
+// to see it.
+func SetOverride(override models.RateOverride) {
+	overrides.Lock()
+	defer overrides.Unlock()
+	overrides.pairs[pairKey(override.Base, override.Currency)] = override
+}
+
+// DeleteOverride unpins the pair in this process.
+func DeleteOverride(base, currency string) bool {
+	overrides.Lock()
+	defer overrides.Unlock()
+	key := pairKey(base, currency)
+	_, ok := overrides.pairs[key]
+	delete(overrides.pairs, key)
+	return ok
+}
+
+// refreshOverrides replaces the cached overrides with the loaded ones once
+// they are older than overridesRefresh. One caller loads at a time while the
+// others go on with the cache, which is also kept when loading fails.
+func refreshOverrides(ctx context.Context, now time.Time) {
+	overrides.RLock()
+	load, stale := overrides.load, now.Sub(overrides.loadedAt) >= overridesRefresh
+	overrides.RUnlock()
+	if load == nil || !stale || !overrides.loading.CompareAndSwap(false, true) {
+		return
+	}
+	defer overrides.loading.Store(false)
+
+	loaded, err := load(ctx, now)
+	if err != nil {
+		return
+	}
+	pairs := make(map[string]models.RateOverride, len(loaded))
+	for _, override := range loaded {
+		pairs[pairKey(override.Base, override.Currency)] = override
+	}
+
+	overrides.Lock()
+	defer overrides.Unlock()
+	overrides.pairs = pairs
+	overrides.loadedAt = now
+}
+
+// overrideRate finds an active override of the pair, used as is or inverted,
+// and tells when it was set.
+func overrideRate(ctx context.Context, from, to string, now time.Time) (decimal.Decimal, *mytime.MyTime, bool) {
+	refreshOverrides(ctx, now)
+
+	overrides.RLock()
+	defer overrides.RUnlock()
+
+	if override, ok := overrides.pairs[pairKey(from, to)]; ok && now.Before(*override.ExpiresAt.Time) {
+		return override.Rate, override.CreatedAt, true
+	}
+	if override, ok := overrides.pairs[pairKey(to, from)]; ok && now.Before(*override.ExpiresAt.Time) {
+		return decimal.NewFromInt(1).DivRound(override.Rate, 12), override.CreatedAt, true
+	}
+	return decimal.Zero, nil, false
+}
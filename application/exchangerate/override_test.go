@@ -0,0 +1,39 @@
+package exchangerate
+
+import (
+	"context"
+	"log"
+	"testing"
+	"time"
+
+	"job/domain/models"
+	"job/presentation/core/mytime"
+
+	"github.com/shopspring/decimal"
+)
+
+func Test_overrideRate_ShouldReturn_SuccessResultLoaded(t *testing.T) {
+	loads := 0
+	expiresAt := time.Now().Add(time.Hour)
+	SetOverrideLoader(func(ctx context.Context, now time.Time) ([]models.RateOverride, error) {
+		loads++
+		return []models.RateOverride{{Base: "RUB", Currency: "USD", Rate: decimal.RequireFromString("0.02"), CreatedAt: &mytime.MyTime{Time: &now}, ExpiresAt: &mytime.MyTime{Time: &expiresAt}}}, nil
+	})
+	defer func() {
+		SetOverrideLoader(nil)
+		DeleteOverride("RUB", "USD")
+	}()
+
+	now := time.Now()
+	rate, _, ok := overrideRate(context.Background(), "USD", "RUB", now)
+	if !ok || rate.String() != "50" {
+		log.Printf("Expected the loaded override inverted to 50, but got %s %v\n", rate, ok)
+		t.Fatal(rate)
+	}
+	overrideRate(context.Background(), "RUB", "USD", now.Add(time.Second))
+	overrideRate(context.Background(), "RUB", "USD", now.Add(overridesRefresh))
+	if loads != 2 {
+		log.Printf("Expected the overrides loaded again after %s only, but got %d loads\n", overridesRefresh, loads)
+		t.Fatal(loads)
+	}
+}
@@ -0,0 +1,90 @@
+package exchangerate
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"sync/atomic"
+
+	"job/domain/money"
+
+	"github.com/shopspring/decimal"
+)
+
+// Policy holds the spreads and rounding rules applied to every conversion.
+// BuySpread is taken when a client sells a currency for the base currency,
+// SellSpread when a client buys a currency with the base currency. Both are
+// fractions of the market rate.
+type Policy struct {
+	BuySpread       decimal.Decimal
+	SellSpread      decimal.Decimal
+	DefaultRounding money.Rounding
+	Rounding        map[string]money.Rounding
+}
+
+var policy atomic.Pointer[Policy]
+
+func init() {
+	policy.Store(&Policy{
+		DefaultRounding: money.Rounding{Mode: money.HalfEven},
+		Rounding:        map[string]money.Rounding{},
+	})
+}
+
+func NewPolicy(buySpread, sellSpread, defaultRounding string, rounding map[string]string) (*Policy, error) {
+	p := &Policy{Rounding: make(map[string]money.Rounding, len(rounding))}
+
+	var err error
+	if p.BuySpread, err = parseSpread("BuySpread", buySpread); err != nil {
+		return nil, err
+	}
+	if p.SellSpread, err = parseSpread("SellSpread", sellSpread); err != nil {
+		return nil, err
+	}
+
+	if defaultRounding == "" {
+		defaultRounding = money.HalfEven
+	}
+	if p.DefaultRounding, err = money.ParseRounding(defaultRounding); err != nil {
+		return nil, err
+	}
+	if p.DefaultRounding.Mode == money.Cash {
+		return nil, errors.New("DefaultRounding can't be cash rounding, set it per currency!")
+	}
+
+	for code, value := range rounding {
+		code = strings.ToUpper(code)
+		mode, err := money.ParseRounding(value)
+		if err != nil {
+			return nil, err
+		}
+		if err := mode.Check(code); err != nil {
+			return nil, err
+		}
+		p.Rounding[code] = mode
+	}
+	return p, nil
+}
+
+// SetPolicy replaces the policy for the conversions that start afterwards.
+func SetPolicy(p *Policy) {
+	policy.Store(p)
+}
+
+func (p *Policy) roundingFor(code string) money.Rounding {
+	if rounding, ok := p.Rounding[code]; ok {
+		return rounding
+	}
+	return p.DefaultRounding
+}
+
+func parseSpread(name, value string) (decimal.Decimal, error) {
+	if value == "" {
+		return decimal.Zero, nil
+	}
+	spread, err := decimal.NewFromString(value)
+	if err != nil || spread.IsNegative() || spread.GreaterThanOrEqual(decimal.NewFromInt(1)) {
+		return decimal.Zero, fmt.Errorf("%s must be a decimal fraction from 0 to 1, not %q!", name, value)
+	}
+	return spread, nil
+}
@@ -1,22 +1,132 @@
 package models
 
 import (
+	"job/domain/currency"
+	"job/domain/money"
 	"job/presentation/core/mytime"
 
 	"github.com/shopspring/decimal"
 )
 
 type Balance struct {
-	ID     *int64           `json:"id"`
-	Amount *decimal.Decimal `json:"amount"`
+	ID          *int64       `json:"id"`
+	Amount      *money.Money `json:"amount"`
+	Conversions []Conversion `json:"conversions,omitempty"`
+}
+
+// Conversion records how an amount was converted so it can be audited.
+// RatedAt is when the market rate was fetched or overridden.
+type Conversion struct {
+	Amount     money.Money     `json:"amount"`
+	MarketRate decimal.Decimal `json:"marketRate"`
+	Spread     decimal.Decimal `json:"spread"`
+	Rate       decimal.Decimal `json:"rate"`
+	Rounding   string          `json:"rounding"`
+	Override   bool            `json:"override"`
+	RatedAt    *mytime.MyTime  `json:"ratedAt"`
+}
+
+// RateOverride pins the price of one unit of Base in Currency until ExpiresAt.
+type RateOverride struct {
+	Base      string          `json:"base"`
+	Currency  string          `json:"currency"`
+	Rate      decimal.Decimal `json:"rate"`
+	CreatedAt *mytime.MyTime  `json:"createdAt"`
+	ExpiresAt *mytime.MyTime  `json:"expiresAt"`
+}
+
+// RateTable lists the price of one unit of Base in other currencies. Overridden
+// names the currencies whose rate comes from a manual override.
+type RateTable struct {
+	Base       string                     `json:"base"`
+	Date       string                     `json:"date"`
+	Rates      map[string]decimal.Decimal `json:"rates"`
+	Overridden []string                   `json:"overridden"`
+}
+
+// Health is the state of the service, ok or fail, with the result of each
+// readiness check.
+type Health struct {
+	Status string                 `json:"status"`
+	Checks map[string]HealthCheck `json:"checks,omitempty"`
+}
+
+type HealthCheck struct {
+	Status string `json:"status"`
+	Detail string `json:"detail,omitempty"`
+	Error  string `json:"error,omitempty"`
+}
+
+// BuildInfo identifies the running binary.
+type BuildInfo struct {
+	Version   string `json:"version"`
+	Commit    string `json:"commit"`
+	BuildTime string `json:"buildTime"`
+	GoVersion string `json:"goVersion"`
+}
+
+// APIClient is a client whose key is kept in the database. Only the hash of
+// the key is stored, so it can't be shown again.
+type APIClient struct {
+	ID        string         `json:"id"`
+	Scopes    []string       `json:"scopes"`
+	CreatedAt *mytime.MyTime `json:"createdAt"`
+	RevokedAt *mytime.MyTime `json:"revokedAt,omitempty"`
+}
+
+// Reconciliation is a balance that differs from the sum of its transactions.
+type Reconciliation struct {
+	ID         int64           `json:"id"`
+	Balance    decimal.Decimal `json:"balance"`
+	Ledger     decimal.Decimal `json:"ledger"`
+	Difference decimal.Decimal `json:"difference"`
 }
 
 type Transaction struct {
-	ID        *int64           `json:"id"`
-	BalanceID *int64           `json:"balance_id"`
-	FromID    *int64           `json:"from_id"`
-	Amount    *decimal.Decimal `json:"amount"`
-	Reason    *string          `json:"reason"`
-	Type      *string          `json:"type"`
-	Date      *mytime.MyTime   `json:"date"`
+	ID         *int64         `json:"id"`
+	BalanceID  *int64         `json:"balance_id"`
+	FromID     *int64         `json:"from_id"`
+	Amount     *money.Money   `json:"amount"`
+	Reason     *string        `json:"reason"`
+	Type       *string        `json:"type"`
+	Date       *mytime.MyTime `json:"date"`
+	QuoteID    *string        `json:"quote_id,omitempty"`
+	Status     *string        `json:"status"`
+	TransferID *string        `json:"transfer_id,omitempty"`
+	ClientID   *string        `json:"client_id,omitempty"`
+
+	Conversions []Conversion `json:"conversions,omitempty"`
+}
+
+// Quote is an exchange rate offered to a client until ExpiresAt. Source is
+// converted into Target at Rate.
+type Quote struct {
+	ID         string          `json:"id"`
+	Source     money.Money     `json:"source"`
+	Target     money.Money     `json:"target"`
+	MarketRate decimal.Decimal `json:"marketRate"`
+	Spread     decimal.Decimal `json:"spread"`
+	Rate       decimal.Decimal `json:"rate"`
+	Rounding   string          `json:"rounding"`
+	Override   bool            `json:"override"`
+	CreatedAt  *mytime.MyTime  `json:"createdAt"`
+	ExpiresAt  *mytime.MyTime  `json:"expiresAt"`
+	UsedAt     *mytime.MyTime  `json:"usedAt,omitempty"`
+}
+
+// BaseAmount is the side of the quote in the base currency, the amount a
+// transfer made under the quote moves between balances.
+func (quote Quote) BaseAmount() (money.Money, bool) {
+	switch money.BaseCurrency() {
+	case quote.Source.Currency:
+		return quote.Source, true
+	case quote.Target.Currency:
+		return quote.Target, true
+	}
+	return money.Money{}, false
+}
+
+type Currency struct {
+	currency.Currency
+	HasRate bool `json:"hasRate"`
 }
@@ -1,20 +1,195 @@
 package models
 
+import (
+	"time"
+
+	"job/presentation/core/mytime"
+)
+
 type Config struct {
 	Application application
+	Logger      logging
 	Database    database
+	Server      server
+	Exchange    exchange
+	Limits      limits
+	RateLimit   rateLimit
+	AccessLog   accessLog
+	Tracing     tracing
+	Health      health
+	Auth        auth
+	JWT         jwtAuth
+	Signing     signing
 }
 
 type database struct {
 	User     string
 	Password string
 	Name     string
-	Host     string 
+	Host     string
 	Port     int
+
+	SSLMode     string
+	SSLRootCert string
+	SSLCert     string
+	SSLKey      string
+
+	ApplicationName  string
+	ConnectTimeout   Duration
+	StatementTimeout Duration
+
+	MaxOpenConns    int
+	MaxIdleConns    int
+	ConnMaxLifetime Duration
+	ConnMaxIdleTime Duration
+
+	PingAttempts int
+	PingBackoff  Duration
+	AutoMigrate  bool
 }
 
 type application struct {
-	Name    string
-	Version string
-	Host    string
+	Name          string
+	Version       string
+	Host          string
+	BaseCurrency  string
+	WatchInterval Duration
+}
+
+type logging struct {
+	Level         string
+	Format        string
+	Output        string
+	File          string
+	MaxSizeMB     int
+	MaxBackups    int
+	MaxAgeDays    int
+	SyslogNetwork string
+	SyslogAddress string
+	SyslogTag     string
+}
+
+type server struct {
+	Port              int
+	MaxBodyBytes      int64
+	ReadTimeout       Duration
+	ReadHeaderTimeout Duration
+	WriteTimeout      Duration
+	IdleTimeout       Duration
+	ShutdownTimeout   Duration
+}
+
+// auth lists the API clients known from config, more can be kept in the
+// database. With Enabled false requests aren't authenticated.
+type auth struct {
+	Enabled bool
+	Clients []apiClient
+}
+
+// apiClient is a client whose KeyHash is the hex SHA-256 of its key.
+type apiClient struct {
+	ID      string
+	KeyHash string
+	Scopes  []string
+}
+
+type exchange struct {
+	ProviderBase    string
+	RatesTTL        Duration
+	BuySpread       string
+	SellSpread      string
+	DefaultRounding string
+	Rounding        map[string]string
+	QuoteTTL        Duration
+}
+
+// limits cap the amount of a single operation, empty means no cap.
+type limits struct {
+	MaxIncome   string
+	MaxOutcome  string
+	MaxTransfer string
+}
+
+// rateLimit is the request quota of one client address, zero means no quota.
+type rateLimit struct {
+	RequestsPerSecond float64
+	Burst             int
+}
+
+// accessLog describes the request log. SampleRate is the share of successful
+// requests logged, Routes sets it per route template; failed requests are
+// always logged. MaskFields lists, comma separated, the body fields to hide.
+type accessLog struct {
+	Enabled      bool
+	SampleRate   float64
+	Routes       map[string]float64
+	LogBodies    bool
+	MaxBodyBytes int
+	MaskFields   string
+}
+
+// tracing names the span exporter: none, stdout or otlp, which sends spans
+// over HTTP to Endpoint as host:port. SampleRatio is the share of new traces
+// recorded.
+type tracing struct {
+	Exporter    string
+	Endpoint    string
+	Insecure    bool
+	SampleRatio float64
+}
+
+// health bounds the readiness checks. MaxRatesAge is how old the exchange
+// rates may be for the service to be ready, zero skips the rates check.
+type health struct {
+	Timeout     Duration
+	MaxRatesAge Duration
+}
+
+// jwtAuth lets users' bearer tokens in, checked against the keys of a JWKS
+// read from JWKSFile or JWKSURL. Tokens are accepted only with [auth]
+// enabled.
+type jwtAuth struct {
+	Enabled         bool
+	JWKSFile        string
+	JWKSURL         string
+	Issuer          string
+	Audience        string
+	Leeway          Duration
+	RefreshInterval Duration
+}
+
+// signing asks for HMAC-signed requests on Routes, by template, each either
+// required or optional. Secrets holds the shared secret of each client by
+// client id; MaxSkew bounds how far the signed timestamp may be from now.
+type signing struct {
+	Routes  map[string]string
+	Secrets map[string]string
+	MaxSkew Duration
+}
+
+// ConfigVersion identifies the active config. Rejected lists the changes
+// that were ignored on reload because they need a restart.
+type ConfigVersion struct {
+	Version  int64          `json:"version"`
+	Checksum string         `json:"checksum"`
+	LoadedAt *mytime.MyTime `json:"loadedAt"`
+	Rejected []string       `json:"rejected"`
+}
+
+// Duration is a time.Duration written in config as "1m30s".
+type Duration struct {
+	time.Duration
+}
+
+func (d *Duration) UnmarshalText(text []byte) error {
+	value, err := time.ParseDuration(string(text))
+	if err != nil {
+		return err
+	}
+	d.Duration = value
+	return nil
+}
+
+func (d Duration) MarshalText() ([]byte, error) {
+	return []byte(d.String()), nil
 }
@@ -1,72 +1,151 @@
 package models
 
 import (
-	"database/sql"
+	"job/domain/money"
+	"job/presentation/core/jsonint"
 	"job/presentation/core/mytime"
+	"strings"
 	"time"
 
 	"github.com/shopspring/decimal"
 )
 
 type BalanceDTO struct {
-	ID     sql.NullInt64
-	Amount sql.NullString
+	ID       jsonint.Optional[int64]
+	Amount   jsonint.Optional[money.Money]
+	Currency jsonint.Optional[string]
 }
 
 type TransactionDTO struct {
-	ID        sql.NullInt64
-	BalanceID sql.NullInt64
-	FromID    sql.NullInt64
-	Amount    sql.NullString
-	Reason    sql.NullString
-	Type      sql.NullString
-	Date      time.Time
+	ID         jsonint.Optional[int64]
+	BalanceID  jsonint.Optional[int64]
+	FromID     jsonint.Optional[int64]
+	Amount     jsonint.Optional[money.Money]
+	Reason     jsonint.Optional[string]
+	Type       jsonint.Optional[string]
+	Date       jsonint.Optional[time.Time]
+	QuoteID    jsonint.Optional[string]
+	Status     jsonint.Optional[string]
+	TransferID jsonint.Optional[string]
+	ClientID   jsonint.Optional[string]
+	Currency   jsonint.Optional[string]
+}
+
+type QuoteDTO struct {
+	ID             jsonint.Optional[string]
+	SourceAmount   jsonint.Optional[decimal.Decimal]
+	SourceCurrency jsonint.Optional[string]
+	TargetAmount   jsonint.Optional[decimal.Decimal]
+	TargetCurrency jsonint.Optional[string]
+	MarketRate     jsonint.Optional[decimal.Decimal]
+	Spread         jsonint.Optional[decimal.Decimal]
+	Rate           jsonint.Optional[decimal.Decimal]
+	Rounding       jsonint.Optional[string]
+	Override       jsonint.Optional[bool]
+	CreatedAt      jsonint.Optional[time.Time]
+	ExpiresAt      jsonint.Optional[time.Time]
+	UsedAt         jsonint.Optional[time.Time]
+}
+
+type RateOverrideDTO struct {
+	Base      jsonint.Optional[string]
+	Currency  jsonint.Optional[string]
+	Rate      jsonint.Optional[decimal.Decimal]
+	CreatedAt jsonint.Optional[time.Time]
+	ExpiresAt jsonint.Optional[time.Time]
+}
+
+type APIClientDTO struct {
+	ID        jsonint.Optional[string]
+	Scopes    jsonint.Optional[string]
+	CreatedAt jsonint.Optional[time.Time]
+	RevokedAt jsonint.Optional[time.Time]
 }
 
 func (user BalanceDTO) GetEntity() Balance {
 	return Balance{
-		ID:     getInt64Pointer(user.ID),
-		Amount: getDecimalPointer(user.Amount),
+		ID:     user.ID.Ptr(),
+		Amount: inCurrency(user.Amount, user.Currency),
 	}
 }
 
 func (transaction TransactionDTO) GetEntity() Transaction {
 	return Transaction{
-		ID:        getInt64Pointer(transaction.ID),
-		BalanceID: getInt64Pointer(transaction.BalanceID),
-		FromID:    getInt64Pointer(transaction.FromID),
-		Amount:    getDecimalPointer(transaction.Amount),
-		Reason:    getStringPointer(transaction.Reason),
-		Type:      getStringPointer(transaction.Type),
-		Date:      getTimePointer(transaction.Date),
+		ID:         transaction.ID.Ptr(),
+		BalanceID:  transaction.BalanceID.Ptr(),
+		FromID:     transaction.FromID.Ptr(),
+		Amount:     inCurrency(transaction.Amount, transaction.Currency),
+		Reason:     transaction.Reason.Ptr(),
+		Type:       transaction.Type.Ptr(),
+		Date:       getTimePointer(transaction.Date),
+		QuoteID:    transaction.QuoteID.Ptr(),
+		Status:     transaction.Status.Ptr(),
+		TransferID: transaction.TransferID.Ptr(),
+		ClientID:   transaction.ClientID.Ptr(),
 	}
 }
 
-func getTimePointer(time time.Time) *mytime.MyTime {
-	if time.IsZero() {
-		return nil
+// inCurrency puts a scanned amount, read as the base currency, in the
+// currency stored next to it.
+func inCurrency(amount jsonint.Optional[money.Money], currency jsonint.Optional[string]) *money.Money {
+	if amount.Valid && currency.Valid {
+		amount.V.Currency = strings.TrimSpace(currency.V)
 	}
-	return &mytime.MyTime{&time}
+	return amount.Ptr()
 }
 
-func getInt64Pointer(value sql.NullInt64) *int64 {
-	if value.Valid {
-		return &value.Int64
+func (override RateOverrideDTO) GetEntity() RateOverride {
+	return RateOverride{
+		Base:      strings.TrimSpace(override.Base.V),
+		Currency:  strings.TrimSpace(override.Currency.V),
+		Rate:      override.Rate.V,
+		CreatedAt: getTimePointer(override.CreatedAt),
+		ExpiresAt: getTimePointer(override.ExpiresAt),
 	}
-	return nil
 }
 
-func getStringPointer(value sql.NullString) *string {
-	if value.Valid {
-		return &value.String
+func (client APIClientDTO) GetEntity() APIClient {
+	scopes := make([]string, 0)
+	for _, scope := range strings.Split(client.Scopes.V, ",") {
+		if scope != "" {
+			scopes = append(scopes, scope)
+		}
 	}
-	return nil
+	return APIClient{
+		ID:        client.ID.V,
+		Scopes:    scopes,
+		CreatedAt: getTimePointer(client.CreatedAt),
+		RevokedAt: getTimePointer(client.RevokedAt),
+	}
+}
+
+func (quote QuoteDTO) GetEntity() (Quote, error) {
+	source, err := money.New(quote.SourceAmount.V, quote.SourceCurrency.V)
+	if err != nil {
+		return Quote{}, err
+	}
+	target, err := money.New(quote.TargetAmount.V, quote.TargetCurrency.V)
+	if err != nil {
+		return Quote{}, err
+	}
+	return Quote{
+		ID:         quote.ID.V,
+		Source:     source,
+		Target:     target,
+		MarketRate: quote.MarketRate.V,
+		Spread:     quote.Spread.V,
+		Rate:       quote.Rate.V,
+		Rounding:   quote.Rounding.V,
+		Override:   quote.Override.V,
+		CreatedAt:  getTimePointer(quote.CreatedAt),
+		ExpiresAt:  getTimePointer(quote.ExpiresAt),
+		UsedAt:     getTimePointer(quote.UsedAt),
+	}, nil
 }
 
-func getDecimalPointer(value sql.NullString) *decimal.Decimal {
-	if value.Valid {
-		n := decimal.RequireFromString(value.String)
-		return &n
+func getTimePointer(date jsonint.Optional[time.Time]) *mytime.MyTime {
+	if !date.Valid || date.V.IsZero() {
+		return nil
 	}
-	return nil
+	return &mytime.MyTime{Time: date.Ptr()}
 }
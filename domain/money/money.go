@@ -0,0 +1,160 @@
+package money
+
+import (
+	"bytes"
+	"database/sql/driver"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+
+	"job/domain/currency"
+
+	"github.com/shopspring/decimal"
+)
+
+// DefaultCurrency is the ledger base currency when none is configured.
+const DefaultCurrency = "RUB"
+
+var baseCurrency = DefaultCurrency
+
+// BaseCurrency is the currency balances are kept in.
+func BaseCurrency() string {
+	return baseCurrency
+}
+
+// SetBaseCurrency changes the currency balances are kept in. It is meant to
+// be called once at startup, before any amount is decoded or scanned.
+func SetBaseCurrency(code string) error {
+	iso, err := currency.Get(code)
+	if err != nil {
+		return err
+	}
+	baseCurrency = iso.Code
+	return nil
+}
+
+// Money is a non-negative amount in a currency. The amount never has more
+// decimal places than the currency's minor unit allows.
+type Money struct {
+	Amount   decimal.Decimal
+	Currency string
+}
+
+type moneyJSON struct {
+	Amount   decimal.Decimal `json:"amount"`
+	Currency string          `json:"currency"`
+}
+
+// New validates amount against the rules of code.
+func New(amount decimal.Decimal, code string) (Money, error) {
+	iso, err := currency.Get(code)
+	if err != nil {
+		return Money{}, err
+	}
+	currency, scale := iso.Code, iso.MinorUnits
+	if amount.IsNegative() {
+		return Money{}, errors.New("Amount must not be negative!")
+	}
+	if !amount.Equal(amount.Truncate(scale)) {
+		return Money{}, fmt.Errorf("Amount must have at most %d decimal places for %s!", scale, currency)
+	}
+	return Money{Amount: amount, Currency: currency}, nil
+}
+
+// Parse validates a decimal string amount against the rules of code.
+func Parse(amount, code string) (Money, error) {
+	value, err := decimal.NewFromString(amount)
+	if err != nil {
+		return Money{}, errors.New("Amount must be decimal!")
+	}
+	return New(value, code)
+}
+
+// Round rounds amount half to even to the minor unit of code.
+func Round(amount decimal.Decimal, code string) (Money, error) {
+	return RoundWith(amount, code, Rounding{Mode: HalfEven})
+}
+
+func Zero(code string) Money {
+	return Money{Amount: decimal.Zero, Currency: strings.ToUpper(code)}
+}
+
+func (m Money) Scale() int32 {
+	iso, _ := currency.Lookup(m.Currency)
+	return iso.MinorUnits
+}
+
+func (m Money) IsZero() bool {
+	return m.Amount.IsZero()
+}
+
+func (m Money) Add(other Money) (Money, error) {
+	if m.Currency != other.Currency {
+		return Money{}, fmt.Errorf("Can't add %s to %s!", other.Currency, m.Currency)
+	}
+	return Money{Amount: m.Amount.Add(other.Amount), Currency: m.Currency}, nil
+}
+
+func (m Money) Sub(other Money) (Money, error) {
+	if m.Currency != other.Currency {
+		return Money{}, fmt.Errorf("Can't subtract %s from %s!", other.Currency, m.Currency)
+	}
+	return New(m.Amount.Sub(other.Amount), m.Currency)
+}
+
+func (m Money) GreaterThanOrEqual(other Money) bool {
+	return m.Currency == other.Currency && m.Amount.GreaterThanOrEqual(other.Amount)
+}
+
+func (m Money) String() string {
+	return fmt.Sprintf("%s %s", m.Amount.StringFixed(m.Scale()), m.Currency)
+}
+
+func (m Money) MarshalJSON() ([]byte, error) {
+	return json.Marshal(struct {
+		Amount   string `json:"amount"`
+		Currency string `json:"currency"`
+	}{
+		Amount:   m.Amount.StringFixed(m.Scale()),
+		Currency: m.Currency,
+	})
+}
+
+// UnmarshalJSON accepts either {"amount": "300", "currency": "USD"} or a bare
+// amount in the base currency, given as a string or a number.
+func (m *Money) UnmarshalJSON(data []byte) error {
+	note := moneyJSON{Currency: BaseCurrency()}
+	if bytes.HasPrefix(bytes.TrimSpace(data), []byte("{")) {
+		decoder := json.NewDecoder(bytes.NewReader(data))
+		decoder.DisallowUnknownFields()
+		if err := decoder.Decode(&note); err != nil {
+			return errors.New("Amount must be decimal and currency must be string!")
+		}
+	} else if err := note.Amount.UnmarshalJSON(data); err != nil {
+		return errors.New("Amount must be decimal!")
+	}
+
+	value, err := New(note.Amount, note.Currency)
+	if err != nil {
+		return err
+	}
+	*m = value
+	return nil
+}
+
+// Value stores the amount only, as balances are kept in the base currency.
+func (m Money) Value() (driver.Value, error) {
+	return m.Amount.StringFixed(m.Scale()), nil
+}
+
+// Scan reads an amount in the base currency.
+func (m *Money) Scan(src interface{}) error {
+	var amount decimal.Decimal
+	if err := amount.Scan(src); err != nil {
+		return err
+	}
+	m.Amount = amount
+	m.Currency = BaseCurrency()
+	return nil
+}
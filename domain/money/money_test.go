@@ -0,0 +1,114 @@
+package money
+
+import (
+	"encoding/json"
+	"log"
+	"testing"
+
+	"github.com/shopspring/decimal"
+)
+
+func Test_Parse_ShouldReturn_ErrorResult(t *testing.T) {
+	for _, amount := range []string{"abc", "-1", "10.001"} {
+		if _, err := Parse(amount, "RUB"); err == nil {
+			log.Printf("Expected error for %s\n", amount)
+			t.Fatal(amount)
+		}
+	}
+
+	if _, err := Parse("10", "XXX"); err == nil {
+		log.Println("Expected error for unknown currency")
+		t.Fatal("XXX")
+	}
+}
+
+func Test_Round_ShouldReturn_SuccessResult(t *testing.T) {
+	value, err := Round(decimal.RequireFromString("10.125"), "USD")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if value.String() != "10.12 USD" {
+		log.Printf("Expected 10.12 USD, but got %s\n", value)
+		t.Fatal(value)
+	}
+
+	value, err = Round(decimal.RequireFromString("99.5"), "JPY")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if value.String() != "100 JPY" {
+		log.Printf("Expected 100 JPY, but got %s\n", value)
+		t.Fatal(value)
+	}
+}
+
+func Test_UnmarshalJSON_ShouldReturn_SuccessResult(t *testing.T) {
+	var value Money
+	if err := json.Unmarshal([]byte(`"300"`), &value); err != nil {
+		t.Fatal(err)
+	}
+	if value.Currency != BaseCurrency() {
+		log.Printf("Expected %s, but got %s\n", BaseCurrency(), value.Currency)
+		t.Fatal(value.Currency)
+	}
+
+	if err := json.Unmarshal([]byte(`{"amount": 12.5, "currency": "usd"}`), &value); err != nil {
+		t.Fatal(err)
+	}
+	body, err := json.Marshal(value)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := `{"amount":"12.50","currency":"USD"}`
+	if string(body) != expected {
+		log.Printf("Expected %s, but got %s\n", expected, body)
+		t.Fatal(string(body))
+	}
+}
+
+func Test_RoundWith_ShouldReturn_SuccessResult(t *testing.T) {
+	cases := []struct {
+		rounding string
+		amount   string
+		expected string
+	}{
+		{"half-even", "10.125", "10.12 CHF"},
+		{"half-up", "10.125", "10.13 CHF"},
+		{"down", "10.129", "10.12 CHF"},
+		{"cash:0.05", "10.125", "10.15 CHF"},
+		{"cash:0.05", "10.12", "10.10 CHF"},
+	}
+
+	for _, c := range cases {
+		rounding, err := ParseRounding(c.rounding)
+		if err != nil {
+			t.Fatal(err)
+		}
+		value, err := RoundWith(decimal.RequireFromString(c.amount), "CHF", rounding)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if value.String() != c.expected {
+			log.Printf("Expected %s for %s, but got %s\n", c.expected, c.rounding, value)
+			t.Fatal(value)
+		}
+	}
+}
+
+func Test_ParseRounding_ShouldReturn_ErrorResult(t *testing.T) {
+	for _, value := range []string{"up", "cash", "cash:-1", "down:1"} {
+		if _, err := ParseRounding(value); err == nil {
+			log.Printf("Expected error for %s\n", value)
+			t.Fatal(value)
+		}
+	}
+
+	rounding, err := ParseRounding("cash:0.5")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := rounding.Check("JPY"); err == nil {
+		log.Println("Expected error for cash:0.5 in JPY")
+		t.Fatal(rounding)
+	}
+}
@@ -0,0 +1,88 @@
+package money
+
+import (
+	"fmt"
+	"strings"
+
+	"job/domain/currency"
+
+	"github.com/shopspring/decimal"
+)
+
+const (
+	HalfEven = "half-even"
+	HalfUp   = "half-up"
+	Down     = "down"
+	Cash     = "cash"
+)
+
+// Rounding says how an amount is brought to the precision of its currency.
+// Cash rounding goes to the nearest multiple of Increment, for example 0.05.
+type Rounding struct {
+	Mode      string
+	Increment decimal.Decimal
+}
+
+// ParseRounding reads "half-even", "half-up", "down" or "cash:<increment>".
+func ParseRounding(value string) (Rounding, error) {
+	mode, increment, hasIncrement := strings.Cut(strings.TrimSpace(strings.ToLower(value)), ":")
+	switch mode {
+	case HalfEven, HalfUp, Down:
+		if hasIncrement {
+			return Rounding{}, fmt.Errorf("Rounding %q takes no increment!", mode)
+		}
+		return Rounding{Mode: mode}, nil
+	case Cash:
+		step, err := decimal.NewFromString(increment)
+		if err != nil || !step.IsPositive() {
+			return Rounding{}, fmt.Errorf("Cash rounding %q must have a positive increment!", value)
+		}
+		return Rounding{Mode: Cash, Increment: step}, nil
+	}
+	return Rounding{}, fmt.Errorf("Rounding %q is not one of half-even, half-up, down, cash:<increment>!", value)
+}
+
+func (r Rounding) String() string {
+	if r.Mode == Cash {
+		return fmt.Sprintf("%s:%s", Cash, r.Increment)
+	}
+	if r.Mode == "" {
+		return HalfEven
+	}
+	return r.Mode
+}
+
+// Check makes sure the rounding never produces more decimal places than code allows.
+func (r Rounding) Check(code string) error {
+	iso, err := currency.Get(code)
+	if err != nil {
+		return err
+	}
+	if r.Mode == Cash && !r.Increment.Equal(r.Increment.Truncate(iso.MinorUnits)) {
+		return fmt.Errorf("Cash increment %s is finer than the minor unit of %s!", r.Increment, iso.Code)
+	}
+	return nil
+}
+
+// RoundWith rounds amount to the minor unit of code using rounding.
+func RoundWith(amount decimal.Decimal, code string, rounding Rounding) (Money, error) {
+	iso, err := currency.Get(code)
+	if err != nil {
+		return Money{}, err
+	}
+
+	switch rounding.Mode {
+	case HalfUp:
+		amount = amount.Round(iso.MinorUnits)
+	case Down:
+		amount = amount.Truncate(iso.MinorUnits)
+	case Cash:
+		if err := rounding.Check(iso.Code); err != nil {
+			return Money{}, err
+		}
+		amount = amount.DivRound(rounding.Increment, 16).Round(0).Mul(rounding.Increment)
+	default:
+		amount = amount.RoundBank(iso.MinorUnits)
+	}
+	return New(amount, iso.Code)
+}
@@ -0,0 +1,107 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"strings"
+
+	"job/domain/models"
+)
+
+var ErrClientNotFound = errors.New("Have no active client with that id!")
+
+// AddClientPg stores a client with the hash of its key.
+func AddClientPg(ctx context.Context, db DBTX, id, keyHash string, scopes []string) error {
+	ctx, db, span := startSpan(ctx, db, "AddClientPg")
+	defer span.End()
+
+	queryString := `INSERT INTO api_clients(id, key_hash, scopes) VALUES ($1, $2, $3);`
+	_, err := db.ExecContext(ctx, queryString, id, keyHash, strings.Join(scopes, ","))
+	if err != nil {
+		if err == ctx.Err() {
+			return errors.New("request cancel")
+		}
+		return err
+	}
+	return nil
+}
+
+// GetClientPg returns the active client whose key hashes to keyHash, nil
+// when there is none.
+func GetClientPg(ctx context.Context, db DBTX, keyHash string) (*models.APIClient, error) {
+	ctx, db, span := startSpan(ctx, db, "GetClientPg")
+	defer span.End()
+
+	queryString := `SELECT id, scopes, created_at, revoked_at FROM api_clients WHERE key_hash = $1 AND revoked_at IS NULL;`
+	rows, err := db.QueryContext(ctx, queryString, keyHash)
+	if err != nil {
+		if err == ctx.Err() {
+			return nil, errors.New("request cancel")
+		}
+		return nil, err
+	}
+	defer rows.Close()
+
+	clients, err := scanClients(rows)
+	if err != nil || len(clients) == 0 {
+		return nil, err
+	}
+	return &clients[0], nil
+}
+
+// ListClientsPg returns every client, revoked ones included.
+func ListClientsPg(ctx context.Context, db DBTX) ([]models.APIClient, error) {
+	ctx, db, span := startSpan(ctx, db, "ListClientsPg")
+	defer span.End()
+
+	queryString := `SELECT id, scopes, created_at, revoked_at FROM api_clients ORDER BY id;`
+	rows, err := db.QueryContext(ctx, queryString)
+	if err != nil {
+		if err == ctx.Err() {
+			return nil, errors.New("request cancel")
+		}
+		return nil, err
+	}
+	defer rows.Close()
+
+	return scanClients(rows)
+}
+
+// RevokeClientPg stops the key of the client id from being accepted.
+func RevokeClientPg(ctx context.Context, db DBTX, id string) error {
+	ctx, db, span := startSpan(ctx, db, "RevokeClientPg")
+	defer span.End()
+
+	queryString := `UPDATE api_clients SET revoked_at = now() WHERE id = $1 AND revoked_at IS NULL;`
+	res, err := db.ExecContext(ctx, queryString, id)
+	if err != nil {
+		if err == ctx.Err() {
+			return errors.New("request cancel")
+		}
+		return err
+	}
+
+	r, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if r == 0 {
+		return ErrClientNotFound
+	}
+	return nil
+}
+
+func scanClients(rows *sql.Rows) ([]models.APIClient, error) {
+	var clients = make([]models.APIClient, 0)
+
+	for rows.Next() {
+		var client models.APIClientDTO
+		if err := rows.Scan(&client.ID, &client.Scopes, &client.CreatedAt, &client.RevokedAt); err != nil {
+			return nil, err
+		}
+		clients = append(clients, client.GetEntity())
+	}
+
+	return clients, rows.Err()
+}
@@ -0,0 +1,129 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"log"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	_ "github.com/lib/pq"
+)
+
+// PgConfig describes how to reach Postgres and how big the pool may grow.
+// Zero durations and counts leave the driver defaults.
+type PgConfig struct {
+	User     string
+	Password string
+	Name     string
+	Host     string
+	Port     int
+
+	SSLMode     string
+	SSLRootCert string
+	SSLCert     string
+	SSLKey      string
+
+	ApplicationName  string
+	ConnectTimeout   time.Duration
+	StatementTimeout time.Duration
+
+	MaxOpenConns    int
+	MaxIdleConns    int
+	ConnMaxLifetime time.Duration
+	ConnMaxIdleTime time.Duration
+}
+
+// DSN renders conf as a lib/pq connection string, quoting every value.
+func (conf PgConfig) DSN() string {
+	params := map[string]string{
+		"host":     conf.Host,
+		"port":     strconv.Itoa(conf.Port),
+		"user":     conf.User,
+		"password": conf.Password,
+		"dbname":   conf.Name,
+		"sslmode":  conf.SSLMode,
+	}
+	optional := map[string]string{
+		"sslrootcert":      conf.SSLRootCert,
+		"sslcert":          conf.SSLCert,
+		"sslkey":           conf.SSLKey,
+		"application_name": conf.ApplicationName,
+	}
+	if conf.ConnectTimeout > 0 {
+		// connect_timeout is whole seconds, round up so it never becomes 0.
+		optional["connect_timeout"] = strconv.FormatInt(int64((conf.ConnectTimeout+time.Second-1)/time.Second), 10)
+	}
+	if conf.StatementTimeout > 0 {
+		optional["statement_timeout"] = strconv.FormatInt(conf.StatementTimeout.Milliseconds(), 10)
+	}
+	for key, value := range optional {
+		if value != "" {
+			params[key] = value
+		}
+	}
+	if params["sslmode"] == "" {
+		params["sslmode"] = "disable"
+	}
+
+	keys := make([]string, 0, len(params))
+	for key := range params {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	pairs := make([]string, 0, len(keys))
+	for _, key := range keys {
+		value := strings.NewReplacer(`\`, `\\`, `'`, `\'`).Replace(params[key])
+		pairs = append(pairs, key+"='"+value+"'")
+	}
+	return strings.Join(pairs, " ")
+}
+
+// NewPgDatabase opens a pool sized by conf. It doesn't connect yet, see PingPg.
+func NewPgDatabase(conf PgConfig) (*sql.DB, error) {
+	db, err := sql.Open("postgres", conf.DSN())
+	if err != nil {
+		log.Println(err)
+		return nil, err
+	}
+
+	if conf.MaxOpenConns > 0 {
+		db.SetMaxOpenConns(conf.MaxOpenConns)
+	}
+	if conf.MaxIdleConns > 0 {
+		db.SetMaxIdleConns(conf.MaxIdleConns)
+	}
+	if conf.ConnMaxLifetime > 0 {
+		db.SetConnMaxLifetime(conf.ConnMaxLifetime)
+	}
+	if conf.ConnMaxIdleTime > 0 {
+		db.SetConnMaxIdleTime(conf.ConnMaxIdleTime)
+	}
+	return db, nil
+}
+
+// PingPg tries to reach the database up to attempts times, doubling the wait
+// after each failure starting from backoff. It returns the last error.
+func PingPg(ctx context.Context, db *sql.DB, attempts int, backoff time.Duration) error {
+	var err error
+	for attempt := 1; attempt <= attempts; attempt++ {
+		if err = db.PingContext(ctx); err == nil {
+			return nil
+		}
+		if attempt == attempts {
+			break
+		}
+		log.Printf("Database ping %d of %d failed, retrying in %s: %v", attempt, attempts, backoff, err)
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(backoff):
+		}
+		backoff *= 2
+	}
+	return err
+}
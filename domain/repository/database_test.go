@@ -0,0 +1,28 @@
+package repository
+
+import (
+	"log"
+	"testing"
+	"time"
+)
+
+func Test_DSN_ShouldReturn_SuccessResult(t *testing.T) {
+	conf := PgConfig{
+		User:             "balance",
+		Password:         `p a's\s`,
+		Name:             "balances",
+		Host:             "db",
+		Port:             5432,
+		SSLMode:          "verify-full",
+		SSLRootCert:      "/etc/ssl/root.crt",
+		ApplicationName:  "balanceapp",
+		ConnectTimeout:   1500 * time.Millisecond,
+		StatementTimeout: 30 * time.Second,
+	}
+
+	expected := `application_name='balanceapp' connect_timeout='2' dbname='balances' host='db' password='p a\'s\\s' port='5432' sslmode='verify-full' sslrootcert='/etc/ssl/root.crt' statement_timeout='30000' user='balance'`
+	if dsn := conf.DSN(); dsn != expected {
+		log.Printf("Expected %s, but got %s\n", expected, dsn)
+		t.Fatal(dsn)
+	}
+}
@@ -0,0 +1,25 @@
+DO $$
+BEGIN
+	CREATE TYPE transaction_type AS ENUM ('income', 'outcome');
+EXCEPTION
+	WHEN duplicate_object THEN NULL;
+END
+$$;
+
+CREATE TABLE IF NOT EXISTS balances
+(
+	id SERIAL PRIMARY KEY,
+	balance DECIMAL CHECK (balance >= 0)
+);
+
+CREATE TABLE IF NOT EXISTS transactions
+(
+	id SERIAL PRIMARY KEY,
+	balance_id INTEGER,
+	from_id INTEGER,
+	amount DECIMAL CHECK (amount >= 0),
+	reason CHARACTER VARYING(50) NOT NULL,
+	type transaction_type NOT NULL,
+	date timestamptz NOT NULL,
+	FOREIGN KEY(balance_id) REFERENCES balances(id) ON DELETE CASCADE
+);
@@ -0,0 +1,18 @@
+CREATE TABLE IF NOT EXISTS quotes
+(
+	id CHARACTER VARYING(27) PRIMARY KEY,
+	source_amount DECIMAL NOT NULL CHECK (source_amount > 0),
+	source_currency CHARACTER(3) NOT NULL,
+	target_amount DECIMAL NOT NULL CHECK (target_amount >= 0),
+	target_currency CHARACTER(3) NOT NULL,
+	market_rate DECIMAL NOT NULL,
+	spread DECIMAL NOT NULL,
+	rate DECIMAL NOT NULL,
+	rounding CHARACTER VARYING(32) NOT NULL,
+	override BOOLEAN NOT NULL DEFAULT false,
+	created_at timestamptz NOT NULL,
+	expires_at timestamptz NOT NULL,
+	used_at timestamptz
+);
+
+ALTER TABLE transactions ADD COLUMN IF NOT EXISTS quote_id CHARACTER VARYING(27) REFERENCES quotes(id);
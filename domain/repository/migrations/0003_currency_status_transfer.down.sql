@@ -0,0 +1,5 @@
+DROP INDEX IF EXISTS transactions_transfer_id;
+ALTER TABLE transactions DROP COLUMN IF EXISTS transfer_id;
+ALTER TABLE transactions DROP COLUMN IF EXISTS status;
+ALTER TABLE transactions DROP COLUMN IF EXISTS currency;
+ALTER TABLE balances DROP COLUMN IF EXISTS currency;
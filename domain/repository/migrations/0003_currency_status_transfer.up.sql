@@ -0,0 +1,14 @@
+-- Existing rows were kept in the base currency the migrator passes in.
+ALTER TABLE balances ADD COLUMN IF NOT EXISTS currency CHARACTER(3);
+UPDATE balances SET currency = current_setting('balanceapp.base_currency') WHERE currency IS NULL;
+ALTER TABLE balances ALTER COLUMN currency SET NOT NULL;
+
+ALTER TABLE transactions ADD COLUMN IF NOT EXISTS currency CHARACTER(3);
+UPDATE transactions SET currency = current_setting('balanceapp.base_currency') WHERE currency IS NULL;
+ALTER TABLE transactions ALTER COLUMN currency SET NOT NULL;
+
+ALTER TABLE transactions ADD COLUMN IF NOT EXISTS status CHARACTER VARYING(16) NOT NULL DEFAULT 'completed';
+
+-- Both rows of a transfer share its id; transfers made before have none.
+ALTER TABLE transactions ADD COLUMN IF NOT EXISTS transfer_id CHARACTER VARYING(27);
+CREATE INDEX IF NOT EXISTS transactions_transfer_id ON transactions (transfer_id);
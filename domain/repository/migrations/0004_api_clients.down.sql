@@ -0,0 +1,4 @@
+DROP INDEX IF EXISTS transactions_client_id;
+ALTER TABLE transactions DROP COLUMN IF EXISTS client_id;
+
+DROP TABLE IF EXISTS api_clients;
@@ -0,0 +1,14 @@
+-- Clients whose API keys live in the database. Only the SHA-256 hash of a key
+-- is stored; scopes are comma separated.
+CREATE TABLE IF NOT EXISTS api_clients
+(
+	id CHARACTER VARYING(64) PRIMARY KEY,
+	key_hash CHARACTER(64) NOT NULL UNIQUE,
+	scopes TEXT NOT NULL DEFAULT '',
+	created_at timestamptz NOT NULL DEFAULT now(),
+	revoked_at timestamptz
+);
+
+-- The client that made each operation; rows written before have none.
+ALTER TABLE transactions ADD COLUMN IF NOT EXISTS client_id CHARACTER VARYING(64);
+CREATE INDEX IF NOT EXISTS transactions_client_id ON transactions (client_id);
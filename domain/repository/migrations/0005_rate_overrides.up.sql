@@ -0,0 +1,11 @@
+-- Rates pinned through /admin/rates/overrides, shared by every instance. An
+-- override of a pair also prices the reverse pair.
+CREATE TABLE IF NOT EXISTS rate_overrides
+(
+	base CHARACTER(3) NOT NULL,
+	currency CHARACTER(3) NOT NULL,
+	rate DECIMAL NOT NULL CHECK (rate > 0),
+	created_at timestamptz NOT NULL,
+	expires_at timestamptz NOT NULL,
+	PRIMARY KEY (base, currency)
+);
@@ -0,0 +1,216 @@
+// Package migrations versions the database schema. Migrations are SQL files
+// named <version>_<name>.up.sql and <version>_<name>.down.sql, embedded into
+// the binary and applied in version order.
+package migrations
+
+import (
+	"context"
+	"database/sql"
+	"embed"
+	"fmt"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+//go:embed *.sql
+var files embed.FS
+
+// lockKey is the Postgres advisory lock that keeps two migrators apart.
+const lockKey = 7193448026
+
+const createTable = `CREATE TABLE IF NOT EXISTS schema_migrations
+(
+	version BIGINT PRIMARY KEY,
+	name CHARACTER VARYING(255) NOT NULL,
+	applied_at timestamptz NOT NULL
+);`
+
+type Migration struct {
+	Version int64
+	Name    string
+	Up      string
+	Down    string
+}
+
+// Load reads the embedded migrations ordered by version.
+func Load() ([]Migration, error) {
+	names, err := files.ReadDir(".")
+	if err != nil {
+		return nil, err
+	}
+
+	byVersion := make(map[int64]*Migration)
+	for _, entry := range names {
+		file := entry.Name()
+		stem, direction := strings.TrimSuffix(file, ".sql"), ""
+		switch {
+		case strings.HasSuffix(stem, ".up"):
+			stem, direction = strings.TrimSuffix(stem, ".up"), "up"
+		case strings.HasSuffix(stem, ".down"):
+			stem, direction = strings.TrimSuffix(stem, ".down"), "down"
+		default:
+			return nil, fmt.Errorf("Migration %s must end in .up.sql or .down.sql!", file)
+		}
+		number, name, ok := strings.Cut(stem, "_")
+		version, err := strconv.ParseInt(number, 10, 64)
+		if !ok || err != nil || version <= 0 {
+			return nil, fmt.Errorf("Migration %s must start with a positive version!", file)
+		}
+
+		body, err := files.ReadFile(path.Join(".", file))
+		if err != nil {
+			return nil, err
+		}
+		migration, ok := byVersion[version]
+		if !ok {
+			migration = &Migration{Version: version, Name: name}
+			byVersion[version] = migration
+		}
+		if direction == "up" {
+			migration.Up = string(body)
+		} else {
+			migration.Down = string(body)
+		}
+	}
+
+	migrations := make([]Migration, 0, len(byVersion))
+	for _, migration := range byVersion {
+		if migration.Up == "" || migration.Down == "" {
+			return nil, fmt.Errorf("Migration %d needs both an up and a down file!", migration.Version)
+		}
+		migrations = append(migrations, *migration)
+	}
+	sort.Slice(migrations, func(i, j int) bool { return migrations[i].Version < migrations[j].Version })
+	return migrations, nil
+}
+
+// Migrator applies migrations to one database. BaseCurrency is handed to the
+// migrations as the balanceapp.base_currency setting, for backfilling rows
+// written before balances had a currency.
+type Migrator struct {
+	db           *sql.DB
+	migrations   []Migration
+	baseCurrency string
+}
+
+func New(db *sql.DB, baseCurrency string) (*Migrator, error) {
+	migrations, err := Load()
+	if err != nil {
+		return nil, err
+	}
+	return &Migrator{db: db, migrations: migrations, baseCurrency: baseCurrency}, nil
+}
+
+// Latest is the version the embedded migrations lead to.
+func (m *Migrator) Latest() int64 {
+	if len(m.migrations) == 0 {
+		return 0
+	}
+	return m.migrations[len(m.migrations)-1].Version
+}
+
+// Current reads the highest applied version, 0 for an empty database.
+func (m *Migrator) Current(ctx context.Context) (int64, error) {
+	var exists bool
+	if err := m.db.QueryRowContext(ctx, `SELECT to_regclass('schema_migrations') IS NOT NULL`).Scan(&exists); err != nil {
+		return 0, err
+	}
+	if !exists {
+		return 0, nil
+	}
+	var version sql.NullInt64
+	if err := m.db.QueryRowContext(ctx, `SELECT max(version) FROM schema_migrations`).Scan(&version); err != nil {
+		return 0, err
+	}
+	return version.Int64, nil
+}
+
+// Up applies every pending migration and returns the ones it applied.
+func (m *Migrator) Up(ctx context.Context) ([]Migration, error) {
+	return m.run(ctx, func(current int64) []Migration {
+		pending := make([]Migration, 0)
+		for _, migration := range m.migrations {
+			if migration.Version > current {
+				pending = append(pending, migration)
+			}
+		}
+		return pending
+	}, true)
+}
+
+// Down reverts the last steps applied migrations and returns them.
+func (m *Migrator) Down(ctx context.Context, steps int) ([]Migration, error) {
+	return m.run(ctx, func(current int64) []Migration {
+		applied := make([]Migration, 0)
+		for i := len(m.migrations) - 1; i >= 0 && len(applied) < steps; i-- {
+			if m.migrations[i].Version <= current {
+				applied = append(applied, m.migrations[i])
+			}
+		}
+		return applied
+	}, false)
+}
+
+// run picks the migrations under the advisory lock, so that a concurrent
+// migrator sees the versions this one applied, and applies each in its own
+// database transaction.
+func (m *Migrator) run(ctx context.Context, pick func(current int64) []Migration, up bool) ([]Migration, error) {
+	conn, err := m.db.Conn(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+
+	if _, err := conn.ExecContext(ctx, `SELECT pg_advisory_lock($1)`, lockKey); err != nil {
+		return nil, err
+	}
+	defer conn.ExecContext(context.Background(), `SELECT pg_advisory_unlock($1)`, lockKey)
+
+	if _, err := conn.ExecContext(ctx, createTable); err != nil {
+		return nil, err
+	}
+	current, err := m.Current(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	done := make([]Migration, 0)
+	for _, migration := range pick(current) {
+		if err := m.apply(ctx, conn, migration, up); err != nil {
+			return done, fmt.Errorf("migration %d_%s: %w", migration.Version, migration.Name, err)
+		}
+		done = append(done, migration)
+	}
+	return done, nil
+}
+
+func (m *Migrator) apply(ctx context.Context, conn *sql.Conn, migration Migration, up bool) error {
+	tx, err := conn.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.ExecContext(ctx, `SELECT set_config('balanceapp.base_currency', $1, true)`, m.baseCurrency); err != nil {
+		return err
+	}
+
+	if up {
+		if _, err := tx.ExecContext(ctx, migration.Up); err != nil {
+			return err
+		}
+		_, err = tx.ExecContext(ctx, `INSERT INTO schema_migrations (version, name, applied_at) VALUES ($1, $2, $3)`, migration.Version, migration.Name, time.Now())
+	} else {
+		if _, err := tx.ExecContext(ctx, migration.Down); err != nil {
+			return err
+		}
+		_, err = tx.ExecContext(ctx, `DELETE FROM schema_migrations WHERE version = $1`, migration.Version)
+	}
+	if err != nil {
+		return err
+	}
+	return tx.Commit()
+}
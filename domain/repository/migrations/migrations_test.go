@@ -0,0 +1,28 @@
+package migrations
+
+import (
+	"log"
+	"strings"
+	"testing"
+)
+
+func Test_Load_ShouldReturn_SuccessResult(t *testing.T) {
+	migrations, err := Load()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(migrations) < 3 {
+		log.Printf("Expected at least 3 migrations, but got %d\n", len(migrations))
+		t.Fatal(len(migrations))
+	}
+	for i, migration := range migrations {
+		if migration.Version != int64(i+1) {
+			log.Printf("Expected version %d, but got %d\n", i+1, migration.Version)
+			t.Fatal(migration.Version)
+		}
+		if strings.TrimSpace(migration.Up) == "" || strings.TrimSpace(migration.Down) == "" {
+			log.Printf("Expected up and down SQL for %d_%s\n", migration.Version, migration.Name)
+			t.Fatal(migration.Name)
+		}
+	}
+}
@@ -0,0 +1,90 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"job/domain/models"
+	"time"
+)
+
+var ErrQuoteUnavailable = errors.New("Quote is expired or has already been used!")
+
+func AddQuotePg(ctx context.Context, db DBTX, quote models.Quote) error {
+	ctx, db, span := startSpan(ctx, db, "AddQuotePg")
+	defer span.End()
+
+	queryString := `INSERT INTO quotes(id, source_amount, source_currency, target_amount, target_currency, market_rate, spread, rate, rounding, override, created_at, expires_at)
+	VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12);`
+
+	_, err := db.ExecContext(ctx, queryString, quote.ID,
+		quote.Source, quote.Source.Currency, quote.Target, quote.Target.Currency,
+		quote.MarketRate, quote.Spread, quote.Rate, quote.Rounding, quote.Override,
+		*quote.CreatedAt.Time, *quote.ExpiresAt.Time)
+	if err != nil {
+		if err == ctx.Err() {
+			return errors.New("request cancel")
+		}
+		return err
+	}
+	return nil
+}
+
+// GetQuotePg returns nil when there is no quote with that id.
+func GetQuotePg(ctx context.Context, db DBTX, id string) (*models.Quote, error) {
+	ctx, db, span := startSpan(ctx, db, "GetQuotePg")
+	defer span.End()
+
+	queryString := `SELECT id, source_amount, source_currency, target_amount, target_currency, market_rate, spread, rate, rounding, override, created_at, expires_at, used_at
+	FROM quotes WHERE id = $1;`
+
+	rows, err := db.QueryContext(ctx, queryString, id)
+	if err != nil {
+		if err == ctx.Err() {
+			return nil, errors.New("request cancel")
+		}
+		return nil, err
+	}
+	defer rows.Close()
+
+	if !rows.Next() {
+		return nil, rows.Err()
+	}
+
+	var quoteDTO models.QuoteDTO
+	if err := rows.Scan(&quoteDTO.ID, &quoteDTO.SourceAmount, &quoteDTO.SourceCurrency, &quoteDTO.TargetAmount, &quoteDTO.TargetCurrency,
+		&quoteDTO.MarketRate, &quoteDTO.Spread, &quoteDTO.Rate, &quoteDTO.Rounding, &quoteDTO.Override, &quoteDTO.CreatedAt, &quoteDTO.ExpiresAt, &quoteDTO.UsedAt); err != nil {
+		return nil, err
+	}
+
+	quote, err := quoteDTO.GetEntity()
+	if err != nil {
+		return nil, err
+	}
+	return &quote, nil
+}
+
+// UseQuotePg marks the quote as used unless it has expired or was used before.
+func UseQuotePg(ctx context.Context, db DBTX, id string, now time.Time) error {
+	ctx, db, span := startSpan(ctx, db, "UseQuotePg")
+	defer span.End()
+
+	queryString := `UPDATE quotes SET used_at = $2 WHERE id = $1 AND used_at IS NULL AND expires_at > $2;`
+
+	res, err := db.ExecContext(ctx, queryString, id, now)
+	if err != nil {
+		if err == ctx.Err() {
+			return errors.New("request cancel")
+		}
+		return err
+	}
+
+	r, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if r == 0 {
+		return ErrQuoteUnavailable
+	}
+	return nil
+}
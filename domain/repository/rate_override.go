@@ -0,0 +1,79 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"job/domain/models"
+)
+
+// SaveRateOverridePg stores override, replacing an earlier override of the
+// same pair.
+func SaveRateOverridePg(ctx context.Context, db DBTX, override models.RateOverride) error {
+	ctx, db, span := startSpan(ctx, db, "SaveRateOverridePg")
+	defer span.End()
+
+	queryString := `INSERT INTO rate_overrides(base, currency, rate, created_at, expires_at) VALUES ($1, $2, $3, $4, $5)
+	ON CONFLICT (base, currency) DO UPDATE SET rate = EXCLUDED.rate, created_at = EXCLUDED.created_at, expires_at = EXCLUDED.expires_at;`
+	_, err := db.ExecContext(ctx, queryString, override.Base, override.Currency, override.Rate, *override.CreatedAt.Time, *override.ExpiresAt.Time)
+	if err != nil {
+		if err == ctx.Err() {
+			return errors.New("request cancel")
+		}
+		return err
+	}
+	return nil
+}
+
+// DeleteRateOverridePg removes the override of the pair and tells whether
+// there was an active one.
+func DeleteRateOverridePg(ctx context.Context, db DBTX, base, currency string, now time.Time) (bool, error) {
+	ctx, db, span := startSpan(ctx, db, "DeleteRateOverridePg")
+	defer span.End()
+
+	queryString := `DELETE FROM rate_overrides WHERE base = $1 AND currency = $2 RETURNING expires_at > $3;`
+	rows, err := db.QueryContext(ctx, queryString, base, currency, now)
+	if err != nil {
+		if err == ctx.Err() {
+			return false, errors.New("request cancel")
+		}
+		return false, err
+	}
+	defer rows.Close()
+
+	var active bool
+	for rows.Next() {
+		if err := rows.Scan(&active); err != nil {
+			return false, err
+		}
+	}
+	return active, rows.Err()
+}
+
+// ListRateOverridesPg returns the overrides active at now. Expired ones stay
+// in the table until their pair is overridden again or deleted.
+func ListRateOverridesPg(ctx context.Context, db DBTX, now time.Time) ([]models.RateOverride, error) {
+	ctx, db, span := startSpan(ctx, db, "ListRateOverridesPg")
+	defer span.End()
+
+	queryString := `SELECT base, currency, rate, created_at, expires_at FROM rate_overrides WHERE expires_at > $1 ORDER BY base, currency;`
+	rows, err := db.QueryContext(ctx, queryString, now)
+	if err != nil {
+		if err == ctx.Err() {
+			return nil, errors.New("request cancel")
+		}
+		return nil, err
+	}
+	defer rows.Close()
+
+	var overrides = make([]models.RateOverride, 0)
+	for rows.Next() {
+		var override models.RateOverrideDTO
+		if err := rows.Scan(&override.Base, &override.Currency, &override.Rate, &override.CreatedAt, &override.ExpiresAt); err != nil {
+			return nil, err
+		}
+		overrides = append(overrides, override.GetEntity())
+	}
+	return overrides, rows.Err()
+}
@@ -7,35 +7,40 @@ import (
 	"fmt"
 	"job/domain/models"
 	"job/presentation/core/jsonint"
-	"log"
 	"time"
 
-	_ "github.com/lib/pq"
+	"github.com/segmentio/ksuid"
 )
 
-func NewPgDatabase(DB_USER, DB_PASSWORD, DB_HOST, DB_NAME string, DB_PORT int) (*sql.DB, error) {
-	dbInfo := fmt.Sprintf("host=%s port=%d user=%s password=%s dbname=%s sslmode=disable", DB_HOST, DB_PORT, DB_USER, DB_PASSWORD, DB_NAME)
-	db, err := sql.Open("postgres", dbInfo)
-	if err != nil {
-		log.Println(err)
-		return nil, err
-	}
-	return db, nil
+// ErrBalanceCurrency is returned when money comes to a balance kept in
+// another currency.
+var ErrBalanceCurrency = errors.New("Balance is kept in another currency!")
+
+// DBTX is satisfied by both *sql.DB and *sql.Tx, so queries can run inside a
+// database transaction or on their own.
+type DBTX interface {
+	ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error)
+	QueryContext(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error)
+	QueryRowContext(ctx context.Context, query string, args ...interface{}) *sql.Row
 }
 
-func GetBalancePg(ctx context.Context, db *sql.DB, id int64) (*models.Balance, error) {
-	rows, err := db.QueryContext(ctx, "SELECT * FROM balances WHERE id = $1", id)
+func GetBalancePg(ctx context.Context, db DBTX, id int64) (*models.Balance, error) {
+	ctx, db, span := startSpan(ctx, db, "GetBalancePg")
+	defer span.End()
+
+	rows, err := db.QueryContext(ctx, "SELECT id, balance, currency FROM balances WHERE id = $1", id)
 	if err != nil {
 		if err == ctx.Err() {
 			return nil, errors.New("request cancel")
 		}
 		return nil, err
 	}
+	defer rows.Close()
 	var balance = models.Balance{}
 
 	for rows.Next() {
 		var balanceDTO models.BalanceDTO
-		if err := rows.Scan(&balanceDTO.ID, &balanceDTO.Amount); err != nil {
+		if err := rows.Scan(&balanceDTO.ID, &balanceDTO.Amount, &balanceDTO.Currency); err != nil {
 			return nil, err
 		}
 		balance = balanceDTO.GetEntity()
@@ -44,8 +49,11 @@ func GetBalancePg(ctx context.Context, db *sql.DB, id int64) (*models.Balance, e
 	return &balance, nil
 }
 
-func GetHistoryPg(ctx context.Context, db *sql.DB, userId int64, order_by, limit, offset string) ([]models.Transaction, error) {
-	queryString := fmt.Sprintf("SELECT * FROM transactions WHERE balance_id = $1 ORDER BY %s LIMIT %s OFFSET %s;", order_by, limit, offset)
+func GetHistoryPg(ctx context.Context, db DBTX, userId int64, order_by, limit, offset string) ([]models.Transaction, error) {
+	ctx, db, span := startSpan(ctx, db, "GetHistoryPg")
+	defer span.End()
+
+	queryString := fmt.Sprintf("SELECT id, balance_id, from_id, amount, reason, type, date, quote_id, status, transfer_id, client_id, currency FROM transactions WHERE balance_id = $1 ORDER BY %s LIMIT %s OFFSET %s;", order_by, limit, offset)
 	rows, err := db.QueryContext(ctx, queryString, userId)
 	if err != nil {
 		if err == ctx.Err() {
@@ -53,50 +61,147 @@ func GetHistoryPg(ctx context.Context, db *sql.DB, userId int64, order_by, limit
 		}
 		return nil, err
 	}
+	defer rows.Close()
+
+	return scanTransactions(rows)
+}
+
+// ExportTransactionsPg lists the transactions of all balances made in
+// [from, to). A zero from or to leaves that end open.
+func ExportTransactionsPg(ctx context.Context, db DBTX, from, to time.Time) ([]models.Transaction, error) {
+	ctx, db, span := startSpan(ctx, db, "ExportTransactionsPg")
+	defer span.End()
+
+	queryString := `SELECT id, balance_id, from_id, amount, reason, type, date, quote_id, status, transfer_id, client_id, currency FROM transactions
+	WHERE ($1::timestamptz IS NULL OR date >= $1) AND ($2::timestamptz IS NULL OR date < $2) ORDER BY id;`
+	rows, err := db.QueryContext(ctx, queryString, nullTime(from), nullTime(to))
+	if err != nil {
+		if err == ctx.Err() {
+			return nil, errors.New("request cancel")
+		}
+		return nil, err
+	}
+	defer rows.Close()
+
+	return scanTransactions(rows)
+}
 
+func nullTime(t time.Time) sql.NullTime {
+	return sql.NullTime{Time: t, Valid: !t.IsZero()}
+}
+
+func scanTransactions(rows *sql.Rows) ([]models.Transaction, error) {
 	var transactions = make([]models.Transaction, 0)
 
 	for rows.Next() {
 		var transaction models.TransactionDTO
-		if err := rows.Scan(&transaction.ID, &transaction.BalanceID, &transaction.FromID, &transaction.Amount, &transaction.Reason, &transaction.Type, &transaction.Date); err != nil {
+		if err := rows.Scan(&transaction.ID, &transaction.BalanceID, &transaction.FromID, &transaction.Amount, &transaction.Reason, &transaction.Type, &transaction.Date, &transaction.QuoteID, &transaction.Status, &transaction.TransferID, &transaction.ClientID, &transaction.Currency); err != nil {
 			return nil, err
 		}
 		transactions = append(transactions, transaction.GetEntity())
 	}
 
-	return transactions, nil
+	return transactions, rows.Err()
 }
 
+// ReconcilePg compares every balance with the sum of its income minus its
+// outcome and returns the balances that disagree with their history.
+func ReconcilePg(ctx context.Context, db DBTX) ([]models.Reconciliation, error) {
+	ctx, db, span := startSpan(ctx, db, "ReconcilePg")
+	defer span.End()
+
+	queryString := `SELECT b.id, b.balance, COALESCE(SUM(CASE WHEN t.type = 'income' THEN t.amount ELSE -t.amount END), 0) AS ledger
+	FROM balances b LEFT JOIN transactions t ON t.balance_id = b.id
+	GROUP BY b.id, b.balance
+	HAVING b.balance <> COALESCE(SUM(CASE WHEN t.type = 'income' THEN t.amount ELSE -t.amount END), 0)
+	ORDER BY b.id;`
+	rows, err := db.QueryContext(ctx, queryString)
+	if err != nil {
+		if err == ctx.Err() {
+			return nil, errors.New("request cancel")
+		}
+		return nil, err
+	}
+	defer rows.Close()
+
+	var mismatches = make([]models.Reconciliation, 0)
+	for rows.Next() {
+		var mismatch models.Reconciliation
+		if err := rows.Scan(&mismatch.ID, &mismatch.Balance, &mismatch.Ledger); err != nil {
+			return nil, err
+		}
+		mismatch.Difference = mismatch.Balance.Sub(mismatch.Ledger)
+		mismatches = append(mismatches, mismatch)
+	}
+
+	return mismatches, rows.Err()
+}
+
+// TransferTransactionPg moves money between two balances in one database
+// transaction. A transfer made under a quote uses the quote up in the same
+// transaction, so a quote can pay for only one transfer.
 func TransferTransactionPg(ctx context.Context, db *sql.DB, transaction jsonint.TransactionJSON) error {
-	err := OutcomeTransactionPg(ctx, db, transaction)
+	ctx, span := tracer.Start(ctx, "repository.TransferTransactionPg")
+	defer span.End()
+
+	tx, err := db.BeginTx(ctx, nil)
 	if err != nil {
+		if err == ctx.Err() {
+			return errors.New("request cancel")
+		}
 		return err
 	}
+	defer tx.Rollback()
 
-	err = IncomeTransactionPg(ctx, db, transaction)
+	err = TransferPg(ctx, tx, transaction)
 	if err != nil {
 		return err
 	}
 
-	return nil
+	return tx.Commit()
 }
 
-func IncomeTransactionPg(ctx context.Context, db *sql.DB, transaction jsonint.TransactionJSON) error {
-	queryString := `INSERT INTO balances (id, balance)
-					VALUES ($1, $2)
-					ON CONFLICT (id) DO UPDATE SET balance = balances.balance + EXCLUDED.balance;`
+// TransferPg writes both sides of a transfer through tx, which must be a
+// database transaction the caller commits or rolls back. Both rows get the
+// transfer id of transaction, or a new one if it has none.
+func TransferPg(ctx context.Context, tx DBTX, transaction jsonint.TransactionJSON) error {
+	ctx, tx, span := startSpan(ctx, tx, "TransferPg")
+	defer span.End()
 
-	res, err := db.Exec(queryString, transaction.ToId.Value, transaction.Amount.Value)
-	if err != nil {
-		if err == ctx.Err() {
-			return errors.New("request cancel")
+	if !transaction.TransferId.Valid {
+		transaction.TransferId = jsonint.Some(ksuid.New().String())
+	}
+	if transaction.QuoteId.Valid {
+		err := UseQuotePg(ctx, tx, transaction.QuoteId.V, time.Now())
+		if err != nil {
+			return err
 		}
+	}
+
+	err := OutcomeTransactionPg(ctx, tx, transaction)
+	if err != nil {
 		return err
 	}
 
-	transaction.Type.Value = "income"
+	if transaction.Credit.Valid {
+		transaction.Amount = transaction.Credit
+	}
+	return IncomeTransactionPg(ctx, tx, transaction)
+}
+
+// IncomeTransactionPg adds the amount to ToId, opening the balance in the
+// currency of the amount if there is none. A balance kept in another
+// currency is left alone and ErrBalanceCurrency returned.
+func IncomeTransactionPg(ctx context.Context, db DBTX, transaction jsonint.TransactionJSON) error {
+	ctx, db, span := startSpan(ctx, db, "IncomeTransactionPg")
+	defer span.End()
 
-	err = AddTransactionInformationPg(ctx, db, transaction)
+	queryString := `INSERT INTO balances (id, balance, currency)
+					VALUES ($1, $2, $3)
+					ON CONFLICT (id) DO UPDATE SET balance = balances.balance + EXCLUDED.balance
+					WHERE balances.currency = EXCLUDED.currency;`
+
+	res, err := db.ExecContext(ctx, queryString, transaction.ToId.V, transaction.Amount.V, transaction.Amount.V.Currency)
 	if err != nil {
 		if err == ctx.Err() {
 			return errors.New("request cancel")
@@ -113,16 +218,12 @@ func IncomeTransactionPg(ctx context.Context, db *sql.DB, transaction jsonint.Tr
 	}
 
 	if r == 0 {
-		err = errors.New("Have no balance with that id!")
-		return err
+		return ErrBalanceCurrency
 	}
 
-	return nil
-}
+	transaction.Type.V = "income"
 
-func OutcomeTransactionPg(ctx context.Context, db *sql.DB, transaction jsonint.TransactionJSON) error {
-	queryString := `UPDATE balances SET balance = balance - $1 WHERE id = $2;`
-	res, err := db.Exec(queryString, transaction.Amount.Value, transaction.FromId.Value)
+	err = AddTransactionInformationPg(ctx, db, transaction)
 	if err != nil {
 		if err == ctx.Err() {
 			return errors.New("request cancel")
@@ -130,8 +231,17 @@ func OutcomeTransactionPg(ctx context.Context, db *sql.DB, transaction jsonint.T
 		return err
 	}
 
-	transaction.Type.Value = "outcome"
-	err = AddTransactionInformationPg(ctx, db, transaction)
+	return nil
+}
+
+// OutcomeTransactionPg takes the amount from FromId, which must be kept in
+// the currency of the amount.
+func OutcomeTransactionPg(ctx context.Context, db DBTX, transaction jsonint.TransactionJSON) error {
+	ctx, db, span := startSpan(ctx, db, "OutcomeTransactionPg")
+	defer span.End()
+
+	queryString := `UPDATE balances SET balance = balance - $1 WHERE id = $2 AND currency = $3;`
+	res, err := db.ExecContext(ctx, queryString, transaction.Amount.V, transaction.FromId.V, transaction.Amount.V.Currency)
 	if err != nil {
 		if err == ctx.Err() {
 			return errors.New("request cancel")
@@ -148,29 +258,41 @@ func OutcomeTransactionPg(ctx context.Context, db *sql.DB, transaction jsonint.T
 	}
 
 	if r == 0 {
-		err = errors.New("Have no user with that id!")
+		err = errors.New("Have no " + transaction.Amount.V.Currency + " balance with that id!")
+		return err
+	}
+
+	transaction.Type.V = "outcome"
+	err = AddTransactionInformationPg(ctx, db, transaction)
+	if err != nil {
+		if err == ctx.Err() {
+			return errors.New("request cancel")
+		}
 		return err
 	}
 
 	return nil
 }
 
-func AddTransactionInformationPg(ctx context.Context, db *sql.DB, transaction jsonint.TransactionJSON) error {
-	queryString := `INSERT INTO transactions(balance_id, from_id, amount, reason, type, date) 
-	VALUES ($1, $2, $3, $4, $5, $6);`
+func AddTransactionInformationPg(ctx context.Context, db DBTX, transaction jsonint.TransactionJSON) error {
+	ctx, db, span := startSpan(ctx, db, "AddTransactionInformationPg")
+	defer span.End()
+
+	queryString := `INSERT INTO transactions(balance_id, from_id, amount, currency, reason, type, date, quote_id, transfer_id, client_id) 
+	VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10);`
 
 	var balance_id, from_id int64
 
-	switch transaction.Type.Value {
+	switch transaction.Type.V {
 	case "outcome":
-		balance_id = transaction.FromId.Value
-		from_id = transaction.ToId.Value
+		balance_id = transaction.FromId.V
+		from_id = transaction.ToId.V
 	case "income":
-		balance_id = transaction.ToId.Value
-		from_id = transaction.FromId.Value
+		balance_id = transaction.ToId.V
+		from_id = transaction.FromId.V
 	}
 
-	res, err := db.Exec(queryString, balance_id, from_id, transaction.Amount.Value, transaction.Reason.Value, transaction.Type.Value, time.Now())
+	res, err := db.ExecContext(ctx, queryString, balance_id, from_id, transaction.Amount.V, transaction.Amount.V.Currency, transaction.Reason.V, transaction.Type.V, time.Now(), transaction.QuoteId, transaction.TransferId, transaction.ClientId)
 	if err != nil {
 		if err == ctx.Err() {
 			return errors.New("request cancel")
@@ -0,0 +1,63 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+
+	"job/presentation/core/tracing"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+)
+
+var tracer = otel.Tracer("job/domain/repository")
+
+// startSpan starts the span of the repository function name and wraps db so
+// that each of its queries gets a child span.
+func startSpan(ctx context.Context, db DBTX, name string) (context.Context, DBTX, trace.Span) {
+	ctx, span := tracer.Start(ctx, "repository."+name, trace.WithAttributes(attribute.String("db.system", "postgresql")))
+	return ctx, tracedDB{db}, span
+}
+
+// tracedDB runs each query in a client span carrying its statement.
+type tracedDB struct {
+	db DBTX
+}
+
+func (t tracedDB) start(ctx context.Context, operation, query string) (context.Context, trace.Span) {
+	return tracer.Start(ctx, "sql."+operation, trace.WithSpanKind(trace.SpanKindClient), trace.WithAttributes(
+		attribute.String("db.system", "postgresql"),
+		attribute.String("db.statement", query),
+	))
+}
+
+func (t tracedDB) ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error) {
+	ctx, span := t.start(ctx, "exec", query)
+	defer span.End()
+	res, err := t.db.ExecContext(ctx, query, args...)
+	if err != nil {
+		return nil, tracing.Fail(span, err)
+	}
+	return res, nil
+}
+
+func (t tracedDB) QueryContext(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error) {
+	ctx, span := t.start(ctx, "query", query)
+	defer span.End()
+	rows, err := t.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, tracing.Fail(span, err)
+	}
+	return rows, nil
+}
+
+func (t tracedDB) QueryRowContext(ctx context.Context, query string, args ...interface{}) *sql.Row {
+	ctx, span := t.start(ctx, "query", query)
+	defer span.End()
+	row := t.db.QueryRowContext(ctx, query, args...)
+	if err := row.Err(); err != nil {
+		tracing.Fail(span, err)
+	}
+	return row
+}
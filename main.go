@@ -1,12 +1,23 @@
 package main
 
 import (
+	"context"
 	"flag"
+	"job/presentation/cli"
 	"job/presentation/controller"
 	"job/presentation/core/config"
+	"job/presentation/core/logger"
 	"job/presentation/core/routes"
+	"job/presentation/core/tracing"
 	"log"
+	"log/slog"
+	"net"
 	"net/http"
+	"os"
+	"os/signal"
+	"strconv"
+	"syscall"
+	"time"
 )
 
 var (
@@ -14,28 +25,123 @@ var (
 )
 
 func main() {
+	flag.Parse()
+	if err := run(); err != nil {
+		slog.Log(context.Background(), logger.LevelCritical, err.Error())
+		os.Exit(1)
+	}
+}
+
+func run() error {
 	conf, err := config.Read(*configPath)
 	if err != nil {
-		log.Println(err)
-		return
+		return err
+	}
+	if flag.NArg() > 0 && flag.Arg(0) != "serve" {
+		commands := cli.NewCLI(conf)
+		defer commands.Close()
+		return commands.Run(context.Background(), flag.Args())
+	}
+
+	stopTracing, err := tracing.Setup(context.Background(), tracing.Settings{
+		Exporter:    conf.Tracing.Exporter,
+		Endpoint:    conf.Tracing.Endpoint,
+		Insecure:    conf.Tracing.Insecure,
+		SampleRatio: conf.Tracing.SampleRatio,
+		Service:     conf.Application.Name,
+		Version:     conf.Application.Version,
+	})
+	if err != nil {
+		return err
 	}
+	defer func() {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		if err := stopTracing(ctx); err != nil {
+			log.Println("Tracing shutdown: " + err.Error())
+		}
+	}()
 
 	env, err := controller.NewEnvironment()
 	if err != nil {
-		log.Println(err)
-		return
+		return err
 	}
+	// Lines logged outside of a request, including through the log package,
+	// go to the configured output too.
+	slog.SetDefault(env.Logger())
 
 	router, err := routes.NewRouter(env, conf)
 	if err != nil {
-		log.Println(err)
-		return
+		env.Close(context.Background())
+		return err
 	}
 
 	server := &http.Server{
-		Addr:    ":8080",
-		Handler: router,
+		Addr:              net.JoinHostPort(conf.Application.Host, strconv.Itoa(conf.Server.Port)),
+		Handler:           router,
+		ReadTimeout:       conf.Server.ReadTimeout.Duration,
+		ReadHeaderTimeout: conf.Server.ReadHeaderTimeout.Duration,
+		WriteTimeout:      conf.Server.WriteTimeout.Duration,
+		IdleTimeout:       conf.Server.IdleTimeout.Duration,
+	}
+
+	stop := make(chan os.Signal, 1)
+	signal.Notify(stop, syscall.SIGINT, syscall.SIGTERM)
+	defer signal.Stop(stop)
+
+	hangup := make(chan os.Signal, 1)
+	signal.Notify(hangup, syscall.SIGHUP)
+	defer signal.Stop(hangup)
+
+	watching, stopWatching := context.WithCancel(context.Background())
+	defer stopWatching()
+	go config.Watch(watching, *configPath, conf.Application.WatchInterval.Duration, reload)
+	go func() {
+		for {
+			select {
+			case <-watching.Done():
+				return
+			case <-hangup:
+				reload()
+			}
+		}
+	}()
+
+	failed := make(chan error, 1)
+	go func() {
+		log.Println("Listen and serve on " + server.Addr)
+		failed <- server.ListenAndServe()
+	}()
+
+	select {
+	case err := <-failed:
+		env.Close(context.Background())
+		return err
+	case sig := <-stop:
+		log.Println("Shutting down on " + sig.String())
+	}
+	env.Drain()
+
+	// Shutdown stops accepting connections and waits for in-flight requests;
+	// Close waits for the money operations within the same deadline.
+	ctx, cancel := context.WithTimeout(context.Background(), conf.Server.ShutdownTimeout.Duration)
+	defer cancel()
+	shutdownErr := server.Shutdown(ctx)
+	if err := env.Close(ctx); err != nil {
+		return err
+	}
+	return shutdownErr
+}
+
+// reload applies the config file again, keeping the settings that need a restart.
+func reload() {
+	version, err := config.Reload(*configPath)
+	if err != nil {
+		log.Println("Config reload rejected: " + err.Error())
+		return
+	}
+	for _, setting := range version.Rejected {
+		log.Println("Config setting " + setting + " needs a restart, change ignored")
 	}
-	log.Println("Listen and serve")
-	server.ListenAndServe()
+	log.Println("Config version " + strconv.FormatInt(version.Version, 10) + " is active")
 }
@@ -0,0 +1,175 @@
+package cli
+
+import (
+	"context"
+	"database/sql"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"text/tabwriter"
+
+	"job/domain/models"
+	"job/presentation/controller"
+)
+
+// CLI runs the operator subcommands of balanceapp against the configured
+// database, reusing the checks of the HTTP API.
+type CLI struct {
+	conf   *models.Config
+	out    io.Writer
+	db     *sql.DB
+	opened bool
+}
+
+type command struct {
+	usage string
+	run   func(c *CLI, ctx context.Context, args []string) error
+}
+
+// commands lists the subcommands in the order the usage shows them.
+var commands = []struct {
+	name string
+	command
+}{
+	{"balance get", command{"balance get <id> [--currency USD,EUR] [--output json|table]", (*CLI).balanceGet}},
+	{"history", command{"history <id> [--order-by id|amount|date] [--limit N] [--offset N] [--output json|table]", (*CLI).history}},
+	{"income", command{"income --to <id> --amount <amount> --reason <reason> [--dry-run] [--output json|table]", (*CLI).income}},
+	{"outcome", command{"outcome --from <id> --amount <amount> --reason <reason> [--dry-run] [--output json|table]", (*CLI).outcome}},
+	{"transfer", command{"transfer --from <id> --to <id> --amount <amount> --reason <reason> [--dry-run] [--output json|table]", (*CLI).transfer}},
+	{"reconcile", command{"reconcile [--output json|table]", (*CLI).reconcile}},
+	{"export", command{"export [--from 2006-01-02] [--to 2006-01-02] [--output json|csv|table]", (*CLI).export}},
+	{"client add", command{"client add <id> --scopes <scope,...> [--output json|table]", (*CLI).clientAdd}},
+	{"client list", command{"client list [--output json|table]", (*CLI).clientList}},
+	{"client revoke", command{"client revoke <id>", (*CLI).clientRevoke}},
+	{"migrate", command{"migrate [up | down [steps] | status]", (*CLI).migrate}},
+}
+
+func NewCLI(conf *models.Config) *CLI {
+	return &CLI{conf: conf, out: os.Stdout}
+}
+
+func (c *CLI) SetOutput(out io.Writer) *CLI {
+	c.out = out
+	return c
+}
+
+// SetDatabase makes the CLI use db instead of opening the configured one.
+func (c *CLI) SetDatabase(db *sql.DB) *CLI {
+	c.db = db
+	return c
+}
+
+// Close closes the database if the CLI opened it.
+func (c *CLI) Close() error {
+	if !c.opened {
+		return nil
+	}
+	return c.db.Close()
+}
+
+// Run runs the subcommand named by the first one or two args.
+func (c *CLI) Run(ctx context.Context, args []string) error {
+	if err := controller.Configure(c.conf); err != nil {
+		return err
+	}
+	for _, cmd := range commands {
+		words := strings.Fields(cmd.name)
+		if len(args) >= len(words) && strings.Join(args[:len(words)], " ") == cmd.name {
+			return cmd.run(c, ctx, args[len(words):])
+		}
+	}
+	return Usage()
+}
+
+// Usage lists the subcommands.
+func Usage() error {
+	lines := []string{"Usage: balanceapp [--config file] <command>", "", "Commands:", "  serve"}
+	for _, cmd := range commands {
+		lines = append(lines, "  "+cmd.usage)
+	}
+	return errors.New(strings.Join(lines, "\n"))
+}
+
+func (c *CLI) database() (*sql.DB, error) {
+	if c.db != nil {
+		return c.db, nil
+	}
+	db, err := controller.NewDatabase(c.conf)
+	if err != nil {
+		return nil, err
+	}
+	c.db, c.opened = db, true
+	return db, nil
+}
+
+// newFlags creates the flag set of a subcommand; its errors are returned,
+// not printed.
+func newFlags(name string) *flag.FlagSet {
+	flags := flag.NewFlagSet(name, flag.ContinueOnError)
+	flags.SetOutput(io.Discard)
+	return flags
+}
+
+// parse parses flags given before, between or after the positional args and
+// returns the positional args.
+func parse(flags *flag.FlagSet, args []string) ([]string, error) {
+	positional := make([]string, 0)
+	for {
+		if err := flags.Parse(args); err != nil {
+			return nil, fmt.Errorf("%s: %v!", flags.Name(), err)
+		}
+		args = flags.Args()
+		if len(args) == 0 {
+			return positional, nil
+		}
+		positional = append(positional, args[0])
+		args = args[1:]
+	}
+}
+
+// checkOutput tells whether the command can print in output; call it before
+// changing anything.
+func checkOutput(output string, allowed ...string) error {
+	for _, format := range allowed {
+		if output == format {
+			return nil
+		}
+	}
+	return fmt.Errorf("Output must be %s, not %q!", strings.Join(allowed, " or "), output)
+}
+
+// print writes value as indented JSON, or rows under header as an aligned
+// table or CSV.
+func (c *CLI) print(output string, value interface{}, header []string, rows [][]string) error {
+	switch output {
+	case "json":
+		body, err := json.MarshalIndent(value, "", "  ")
+		if err != nil {
+			return err
+		}
+		_, err = fmt.Fprintln(c.out, string(body))
+		return err
+	case "table":
+		w := tabwriter.NewWriter(c.out, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(w, strings.Join(header, "\t"))
+		for _, row := range rows {
+			fmt.Fprintln(w, strings.Join(row, "\t"))
+		}
+		return w.Flush()
+	case "csv":
+		w := csv.NewWriter(c.out)
+		if err := w.Write(header); err != nil {
+			return err
+		}
+		if err := w.WriteAll(rows); err != nil {
+			return err
+		}
+		return w.Error()
+	}
+	return checkOutput(output, "json", "table", "csv")
+}
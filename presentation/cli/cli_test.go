@@ -0,0 +1,139 @@
+package cli
+
+import (
+	"bytes"
+	"context"
+	"log"
+	"strings"
+	"testing"
+
+	"job/domain/models"
+
+	"github.com/DATA-DOG/go-sqlmock"
+)
+
+func Test_BalanceGet_ShouldReturn_SuccessResultTable(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer db.Close()
+
+	mock.ExpectQuery("^SELECT (.+) FROM balances WHERE").WithArgs(1).WillReturnRows(sqlmock.NewRows([]string{"id", "balance", "currency"}).AddRow(1, "100", "RUB"))
+
+	var out bytes.Buffer
+	err = NewCLI(new(models.Config)).SetDatabase(db).SetOutput(&out).Run(context.Background(), []string{"balance", "get", "1", "--output", "table"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := "ID  AMOUNT\n1   100.00 RUB\n"
+	if out.String() != expected {
+		log.Printf("Expected %q, but got %q\n", expected, out.String())
+		t.Fatal(out.String())
+	}
+}
+
+func Test_Income_ShouldReturn_SuccessResultDryRun(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer db.Close()
+
+	mock.ExpectBegin()
+	mock.ExpectExec("INSERT INTO balances").WithArgs(1, sqlmock.AnyArg(), "RUB").WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec("INSERT INTO transactions").WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectQuery("^SELECT (.+) FROM balances WHERE").WithArgs(1).WillReturnRows(sqlmock.NewRows([]string{"id", "balance", "currency"}).AddRow(1, "150", "RUB"))
+	mock.ExpectRollback()
+
+	var out bytes.Buffer
+	err = NewCLI(new(models.Config)).SetDatabase(db).SetOutput(&out).Run(context.Background(), []string{"income", "--to", "1", "--amount", "50", "--reason", "refund", "--dry-run"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(out.String(), `"dryRun": true`) {
+		log.Printf("Expected a dry run, but got %s\n", out.String())
+		t.Fatal(out.String())
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func Test_Transfer_ShouldReturn_ErrorResultFunds(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer db.Close()
+
+	mock.ExpectBegin()
+	mock.ExpectQuery("^SELECT (.+) FROM balances WHERE").WithArgs(1).WillReturnRows(sqlmock.NewRows([]string{"id", "balance", "currency"}).AddRow(1, "10", "RUB"))
+	mock.ExpectRollback()
+
+	err = NewCLI(new(models.Config)).SetDatabase(db).SetOutput(new(bytes.Buffer)).Run(context.Background(), []string{"transfer", "--from", "1", "--to", "2", "--amount", "50", "--reason", "fix"})
+	if err == nil || err.Error() != "Not enough money for transaction!" {
+		log.Printf("Expected not enough money, but got %v\n", err)
+		t.Fatal(err)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func Test_Reconcile_ShouldReturn_ErrorResultMismatch(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer db.Close()
+
+	mock.ExpectQuery("^SELECT (.+) FROM balances b LEFT JOIN transactions").WillReturnRows(sqlmock.NewRows([]string{"id", "balance", "ledger"}).AddRow(3, "100", "90"))
+
+	var out bytes.Buffer
+	err = NewCLI(new(models.Config)).SetDatabase(db).SetOutput(&out).Run(context.Background(), []string{"reconcile"})
+	if err == nil {
+		t.Fatal("Expected a mismatch error")
+	}
+	if !strings.Contains(out.String(), `"difference": "10"`) {
+		log.Printf("Expected difference 10, but got %s\n", out.String())
+		t.Fatal(out.String())
+	}
+}
+
+func Test_History_ShouldReturn_ErrorResultOrderBy(t *testing.T) {
+	err := NewCLI(new(models.Config)).SetOutput(new(bytes.Buffer)).Run(context.Background(), []string{"history", "1", "--order-by", "id; DROP TABLE balances"})
+	if err == nil || !strings.HasPrefix(err.Error(), "Order must be") {
+		log.Printf("Expected an order error, but got %v\n", err)
+		t.Fatal(err)
+	}
+}
+
+func Test_ClientAdd_ShouldReturn_SuccessResult(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer db.Close()
+
+	mock.ExpectExec("INSERT INTO api_clients").WithArgs("reporting", sqlmock.AnyArg(), "balances:read,history:read").WillReturnResult(sqlmock.NewResult(0, 1))
+
+	var out bytes.Buffer
+	err = NewCLI(new(models.Config)).SetDatabase(db).SetOutput(&out).Run(context.Background(), []string{"client", "add", "reporting", "--scopes", "balances:read,history:read"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(out.String(), `"key": "`) {
+		log.Printf("Expected the new key, but got %s\n", out.String())
+		t.Fatal(out.String())
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatal(err)
+	}
+
+	err = NewCLI(new(models.Config)).SetDatabase(db).SetOutput(&out).Run(context.Background(), []string{"client", "add", "reporting", "--scopes", "everything"})
+	if err == nil {
+		log.Printf("Expected error for an unknown scope\n")
+		t.Fatal(err)
+	}
+}
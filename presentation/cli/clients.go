@@ -0,0 +1,115 @@
+package cli
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"job/domain/models"
+	"job/domain/repository"
+	"job/presentation/core/auth"
+)
+
+// clientAdd stores a new API client and prints its key, the only time the
+// key is shown.
+func (c *CLI) clientAdd(ctx context.Context, args []string) error {
+	flags := newFlags("client add")
+	output := flags.String("output", "json", "json or table")
+	scopeList := flags.String("scopes", "", "comma separated scopes")
+	positional, err := parse(flags, args)
+	if err != nil {
+		return err
+	}
+	if len(positional) != 1 || positional[0] == "" || len(positional[0]) > 64 {
+		return errors.New("client add takes one client id of up to 64 characters!")
+	}
+	if err := checkOutput(*output, "json", "table"); err != nil {
+		return err
+	}
+	scopes, err := auth.ParseScopes(*scopeList)
+	if err != nil {
+		return err
+	}
+	if len(scopes) == 0 {
+		return errors.New("Scopes must not be empty!")
+	}
+
+	db, err := c.database()
+	if err != nil {
+		return err
+	}
+	key, err := auth.NewKey()
+	if err != nil {
+		return err
+	}
+	if err := repository.AddClientPg(ctx, db, positional[0], auth.HashKey(key), scopes); err != nil {
+		return err
+	}
+
+	created := struct {
+		ID     string   `json:"id"`
+		Key    string   `json:"key"`
+		Scopes []string `json:"scopes"`
+	}{positional[0], key, scopes}
+	return c.print(*output, created, []string{"ID", "KEY", "SCOPES"},
+		[][]string{{created.ID, created.Key, strings.Join(scopes, ",")}})
+}
+
+func (c *CLI) clientList(ctx context.Context, args []string) error {
+	flags := newFlags("client list")
+	output := flags.String("output", "json", "json or table")
+	if _, err := parse(flags, args); err != nil {
+		return err
+	}
+	if err := checkOutput(*output, "json", "table"); err != nil {
+		return err
+	}
+
+	db, err := c.database()
+	if err != nil {
+		return err
+	}
+	clients, err := repository.ListClientsPg(ctx, db)
+	if err != nil {
+		return err
+	}
+	return c.printClients(*output, clients)
+}
+
+func (c *CLI) printClients(output string, clients []models.APIClient) error {
+	header := []string{"ID", "SCOPES", "CREATED", "REVOKED"}
+	rows := make([][]string, 0, len(clients))
+	for _, client := range clients {
+		var created, revoked *time.Time
+		if client.CreatedAt != nil {
+			created = client.CreatedAt.Time
+		}
+		if client.RevokedAt != nil {
+			revoked = client.RevokedAt.Time
+		}
+		rows = append(rows, []string{client.ID, strings.Join(client.Scopes, ","), formatTime(created), formatTime(revoked)})
+	}
+	return c.print(output, clients, header, rows)
+}
+
+func (c *CLI) clientRevoke(ctx context.Context, args []string) error {
+	positional, err := parse(newFlags("client revoke"), args)
+	if err != nil {
+		return err
+	}
+	if len(positional) != 1 {
+		return errors.New("client revoke takes one client id!")
+	}
+
+	db, err := c.database()
+	if err != nil {
+		return err
+	}
+	if err := repository.RevokeClientPg(ctx, db, positional[0]); err != nil {
+		return err
+	}
+	_, err = fmt.Fprintf(c.out, "Revoked client %s\n", positional[0])
+	return err
+}
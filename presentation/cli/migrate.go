@@ -0,0 +1,60 @@
+package cli
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+
+	"job/domain/money"
+	"job/domain/repository/migrations"
+)
+
+// migrate runs "migrate up", "migrate down [steps]" or "migrate status".
+func (c *CLI) migrate(ctx context.Context, args []string) error {
+	command := "up"
+	if len(args) > 0 {
+		command = args[0]
+	}
+	steps := 1
+	switch command {
+	case "up", "status":
+	case "down":
+		if len(args) > 1 {
+			var err error
+			if steps, err = strconv.Atoi(args[1]); err != nil || steps < 1 {
+				return errors.New("Steps must be a positive integer!")
+			}
+		}
+	default:
+		return errors.New("Usage: balanceapp migrate [up | down [steps] | status]")
+	}
+
+	db, err := c.database()
+	if err != nil {
+		return err
+	}
+	migrator, err := migrations.New(db, money.BaseCurrency())
+	if err != nil {
+		return err
+	}
+
+	var done []migrations.Migration
+	switch command {
+	case "up":
+		done, err = migrator.Up(ctx)
+	case "down":
+		done, err = migrator.Down(ctx, steps)
+	case "status":
+		current, err := migrator.Current(ctx)
+		if err != nil {
+			return err
+		}
+		_, err = fmt.Fprintf(c.out, "Schema version %d, latest %d\n", current, migrator.Latest())
+		return err
+	}
+	for _, migration := range done {
+		fmt.Fprintf(c.out, "Migrated %s %d_%s\n", command, migration.Version, migration.Name)
+	}
+	return err
+}
@@ -0,0 +1,204 @@
+package cli
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"flag"
+	"strconv"
+
+	"job/domain/models"
+	"job/domain/money"
+	"job/domain/repository"
+	"job/presentation/core/jsonint"
+	"job/presentation/core/validator"
+
+	"github.com/shopspring/decimal"
+)
+
+// Operation is what a money command prints: the balances it touched as they
+// are after it, or would be for a dry run.
+type Operation struct {
+	DryRun   bool             `json:"dryRun"`
+	Balances []models.Balance `json:"balances"`
+}
+
+// operationFlags are the flags shared by income, outcome and transfer.
+type operationFlags struct {
+	output *string
+	amount *string
+	reason *string
+	dryRun *bool
+}
+
+func newOperationFlags(flags *flag.FlagSet) operationFlags {
+	return operationFlags{
+		output: flags.String("output", "json", "json or table"),
+		amount: flags.String("amount", "", "decimal amount in the base currency"),
+		reason: flags.String("reason", "", "reason recorded with the transaction"),
+		dryRun: flags.Bool("dry-run", false, "roll the change back instead of committing it"),
+	}
+}
+
+// ClientID is recorded as the client of the operations made from the CLI.
+const ClientID = "cli"
+
+// transaction checks the shared flags against limit and builds the transaction.
+func (f operationFlags) transaction(ctx context.Context, limit decimal.Decimal) (jsonint.TransactionJSON, error) {
+	if err := checkOutput(*f.output, "json", "table"); err != nil {
+		return jsonint.TransactionJSON{}, err
+	}
+	if *f.reason == "" {
+		return jsonint.TransactionJSON{}, errors.New("Reason must not be empty!")
+	}
+	amount, err := money.Parse(*f.amount, money.BaseCurrency())
+	if err != nil {
+		return jsonint.TransactionJSON{}, err
+	}
+	if err := validator.ValidateAmount(ctx, amount); err != nil {
+		return jsonint.TransactionJSON{}, err
+	}
+	if err := validator.ValidateLimit(ctx, amount, limit); err != nil {
+		return jsonint.TransactionJSON{}, err
+	}
+	return jsonint.TransactionJSON{
+		Amount:   jsonint.Some(amount),
+		Reason:   jsonint.Some(*f.reason),
+		ClientId: jsonint.Some(ClientID),
+	}, nil
+}
+
+func (c *CLI) limits() (*validator.Limits, error) {
+	return validator.NewLimits(c.conf.Limits.MaxIncome, c.conf.Limits.MaxOutcome, c.conf.Limits.MaxTransfer)
+}
+
+func (c *CLI) income(ctx context.Context, args []string) error {
+	flags := newFlags("income")
+	to := flags.Int64("to", -1, "balance id")
+	op := newOperationFlags(flags)
+	if _, err := parse(flags, args); err != nil {
+		return err
+	}
+	limits, err := c.limits()
+	if err != nil {
+		return err
+	}
+	transaction, err := op.transaction(ctx, limits.Income)
+	if err != nil {
+		return err
+	}
+	if err := validator.ValidateId(ctx, *to); err != nil {
+		return err
+	}
+	transaction.ToId = jsonint.Some(*to)
+	transaction.Type = jsonint.Some("income")
+
+	return c.operate(ctx, op, []int64{*to}, func(tx *sql.Tx) error {
+		return repository.IncomeTransactionPg(ctx, tx, transaction)
+	})
+}
+
+func (c *CLI) outcome(ctx context.Context, args []string) error {
+	flags := newFlags("outcome")
+	from := flags.Int64("from", -1, "balance id")
+	op := newOperationFlags(flags)
+	if _, err := parse(flags, args); err != nil {
+		return err
+	}
+	limits, err := c.limits()
+	if err != nil {
+		return err
+	}
+	transaction, err := op.transaction(ctx, limits.Outcome)
+	if err != nil {
+		return err
+	}
+	if err := validator.ValidateId(ctx, *from); err != nil {
+		return err
+	}
+	transaction.FromId = jsonint.Some(*from)
+	transaction.Type = jsonint.Some("outcome")
+
+	return c.operate(ctx, op, []int64{*from}, func(tx *sql.Tx) error {
+		if err := checkFunds(ctx, tx, *from, transaction.Amount.V); err != nil {
+			return err
+		}
+		return repository.OutcomeTransactionPg(ctx, tx, transaction)
+	})
+}
+
+func (c *CLI) transfer(ctx context.Context, args []string) error {
+	flags := newFlags("transfer")
+	from := flags.Int64("from", -1, "balance id to take the money from")
+	to := flags.Int64("to", -1, "balance id to give the money to")
+	op := newOperationFlags(flags)
+	if _, err := parse(flags, args); err != nil {
+		return err
+	}
+	limits, err := c.limits()
+	if err != nil {
+		return err
+	}
+	transaction, err := op.transaction(ctx, limits.Transfer)
+	if err != nil {
+		return err
+	}
+	if err := validator.ValidateIds(ctx, *from, *to); err != nil {
+		return err
+	}
+	transaction.FromId = jsonint.Some(*from)
+	transaction.ToId = jsonint.Some(*to)
+
+	return c.operate(ctx, op, []int64{*from, *to}, func(tx *sql.Tx) error {
+		if err := checkFunds(ctx, tx, *from, transaction.Amount.V); err != nil {
+			return err
+		}
+		return repository.TransferPg(ctx, tx, transaction)
+	})
+}
+
+func checkFunds(ctx context.Context, tx *sql.Tx, id int64, amount money.Money) error {
+	balance, err := repository.GetBalancePg(ctx, tx, id)
+	if err != nil {
+		return err
+	}
+	if balance.ID == nil {
+		return errors.New("Have no balance with that id!")
+	}
+	return validator.ValidateBalanceForTransaction(ctx, balance.Amount, amount)
+}
+
+// operate runs write in a database transaction, reads the touched balances
+// back and commits, or rolls everything back on --dry-run.
+func (c *CLI) operate(ctx context.Context, op operationFlags, ids []int64, write func(tx *sql.Tx) error) error {
+	db, err := c.database()
+	if err != nil {
+		return err
+	}
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if err := write(tx); err != nil {
+		return err
+	}
+	result := Operation{DryRun: *op.dryRun, Balances: make([]models.Balance, 0, len(ids))}
+	rows := make([][]string, 0, len(ids))
+	for _, id := range ids {
+		balance, err := repository.GetBalancePg(ctx, tx, id)
+		if err != nil {
+			return err
+		}
+		result.Balances = append(result.Balances, *balance)
+		rows = append(rows, []string{strconv.FormatInt(id, 10), deref(balance.Amount), strconv.FormatBool(result.DryRun)})
+	}
+
+	if !result.DryRun {
+		if err := tx.Commit(); err != nil {
+			return err
+		}
+	}
+	return c.print(*op.output, result, []string{"ID", "AMOUNT", "DRY RUN"}, rows)
+}
@@ -0,0 +1,228 @@
+package cli
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"time"
+
+	"job/application/exchangerate"
+	"job/domain/models"
+	"job/domain/repository"
+	"job/presentation/core/validator"
+)
+
+// orderBy maps the --order-by values of history to columns.
+var orderBy = map[string]string{
+	"id":     "id",
+	"amount": "amount",
+	"date":   "date",
+}
+
+func parseId(value string) (int64, error) {
+	id, err := strconv.ParseInt(value, 10, 64)
+	if err != nil {
+		return 0, errors.New("Id must be positive integer!")
+	}
+	return id, validator.ValidateId(context.Background(), id)
+}
+
+func parseDate(value string) (time.Time, error) {
+	if value == "" {
+		return time.Time{}, nil
+	}
+	date, err := time.Parse("2006-01-02", value)
+	if err != nil {
+		return time.Time{}, errors.New("Date must be in format 2006-01-02!")
+	}
+	return date, nil
+}
+
+func formatTime(t *time.Time) string {
+	if t == nil {
+		return ""
+	}
+	return t.Format("2006-01-02 15:04:05")
+}
+
+func deref[T any](value *T) string {
+	if value == nil {
+		return ""
+	}
+	return fmt.Sprint(*value)
+}
+
+func (c *CLI) balanceGet(ctx context.Context, args []string) error {
+	flags := newFlags("balance get")
+	output := flags.String("output", "json", "json or table")
+	currency := flags.String("currency", "", "comma separated display currencies")
+	positional, err := parse(flags, args)
+	if err != nil {
+		return err
+	}
+	if len(positional) != 1 {
+		return errors.New("balance get takes one balance id!")
+	}
+	if err := checkOutput(*output, "json", "table"); err != nil {
+		return err
+	}
+	id, err := parseId(positional[0])
+	if err != nil {
+		return err
+	}
+	currencies := validator.SplitCurrencies(*currency)
+	if err := validator.ValidateCurrencies(ctx, currencies); err != nil {
+		return err
+	}
+
+	db, err := c.database()
+	if err != nil {
+		return err
+	}
+	balance, err := repository.GetBalancePg(ctx, db, id)
+	if err != nil {
+		return err
+	}
+	if balance.ID == nil {
+		return errors.New("Have no balance with that id!")
+	}
+	if len(currencies) > 0 && balance.Amount != nil {
+		balance.Conversions, err = exchangerate.ExchangeCurrencies(ctx, *balance.Amount, currencies)
+		if err != nil {
+			return err
+		}
+	}
+
+	header := []string{"ID", "AMOUNT"}
+	row := []string{strconv.FormatInt(*balance.ID, 10), balance.Amount.String()}
+	for _, conversion := range balance.Conversions {
+		header = append(header, conversion.Amount.Currency)
+		row = append(row, conversion.Amount.String())
+	}
+	return c.print(*output, balance, header, [][]string{row})
+}
+
+func (c *CLI) history(ctx context.Context, args []string) error {
+	flags := newFlags("history")
+	output := flags.String("output", "json", "json or table")
+	order := flags.String("order-by", "id", "id, amount or date")
+	limit := flags.Int("limit", 0, "max transactions, 0 for all")
+	offset := flags.Int("offset", 0, "transactions to skip")
+	positional, err := parse(flags, args)
+	if err != nil {
+		return err
+	}
+	if len(positional) != 1 {
+		return errors.New("history takes one balance id!")
+	}
+	if err := checkOutput(*output, "json", "table"); err != nil {
+		return err
+	}
+	id, err := parseId(positional[0])
+	if err != nil {
+		return err
+	}
+	column, ok := orderBy[*order]
+	if !ok {
+		return fmt.Errorf("Order must be id, amount or date, not %q!", *order)
+	}
+	if *limit < 0 || *offset < 0 {
+		return errors.New("Limit and offset must not be negative!")
+	}
+	limitValue, offsetValue := "null", strconv.Itoa(*offset)
+	if *limit > 0 {
+		limitValue = strconv.Itoa(*limit)
+	}
+
+	db, err := c.database()
+	if err != nil {
+		return err
+	}
+	transactions, err := repository.GetHistoryPg(ctx, db, id, column, limitValue, offsetValue)
+	if err != nil {
+		return err
+	}
+	return c.printTransactions(*output, transactions)
+}
+
+func (c *CLI) export(ctx context.Context, args []string) error {
+	flags := newFlags("export")
+	output := flags.String("output", "json", "json, csv or table")
+	fromDate := flags.String("from", "", "first day, 2006-01-02")
+	toDate := flags.String("to", "", "last day, 2006-01-02")
+	if _, err := parse(flags, args); err != nil {
+		return err
+	}
+	if err := checkOutput(*output, "json", "csv", "table"); err != nil {
+		return err
+	}
+	from, err := parseDate(*fromDate)
+	if err != nil {
+		return err
+	}
+	to, err := parseDate(*toDate)
+	if err != nil {
+		return err
+	}
+	if !to.IsZero() {
+		to = to.AddDate(0, 0, 1)
+	}
+
+	db, err := c.database()
+	if err != nil {
+		return err
+	}
+	transactions, err := repository.ExportTransactionsPg(ctx, db, from, to)
+	if err != nil {
+		return err
+	}
+	return c.printTransactions(*output, transactions)
+}
+
+func (c *CLI) printTransactions(output string, transactions []models.Transaction) error {
+	header := []string{"ID", "BALANCE", "FROM", "TYPE", "AMOUNT", "REASON", "DATE", "STATUS", "TRANSFER", "CLIENT"}
+	rows := make([][]string, 0, len(transactions))
+	for _, t := range transactions {
+		var date *time.Time
+		if t.Date != nil {
+			date = t.Date.Time
+		}
+		rows = append(rows, []string{deref(t.ID), deref(t.BalanceID), deref(t.FromID), deref(t.Type), deref(t.Amount), deref(t.Reason), formatTime(date), deref(t.Status), deref(t.TransferID), deref(t.ClientID)})
+	}
+	return c.print(output, transactions, header, rows)
+}
+
+// reconcile prints the balances that disagree with their transactions and
+// fails if there are any.
+func (c *CLI) reconcile(ctx context.Context, args []string) error {
+	flags := newFlags("reconcile")
+	output := flags.String("output", "json", "json or table")
+	if _, err := parse(flags, args); err != nil {
+		return err
+	}
+	if err := checkOutput(*output, "json", "table"); err != nil {
+		return err
+	}
+
+	db, err := c.database()
+	if err != nil {
+		return err
+	}
+	mismatches, err := repository.ReconcilePg(ctx, db)
+	if err != nil {
+		return err
+	}
+
+	rows := make([][]string, 0, len(mismatches))
+	for _, m := range mismatches {
+		rows = append(rows, []string{strconv.FormatInt(m.ID, 10), m.Balance.String(), m.Ledger.String(), m.Difference.String()})
+	}
+	if err := c.print(*output, mismatches, []string{"ID", "BALANCE", "LEDGER", "DIFFERENCE"}, rows); err != nil {
+		return err
+	}
+	if len(mismatches) > 0 {
+		return fmt.Errorf("%d balances disagree with their transactions!", len(mismatches))
+	}
+	return nil
+}
@@ -0,0 +1,23 @@
+package controller
+
+import (
+	"encoding/json"
+	"log"
+	"net/http"
+
+	"job/presentation/core/config"
+)
+
+func (env *Environment) GetConfigVersion(w http.ResponseWriter, r *http.Request) {
+	body, err := json.Marshal(config.Version())
+	if err != nil {
+		log.Println(err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if _, err := w.Write(body); err != nil {
+		log.Println(err)
+		return
+	}
+}
@@ -2,28 +2,38 @@ package controller
 
 import (
 	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
 	"log"
+	"log/slog"
 	"net/http"
 	"net/http/httptest"
+	"strings"
 	"testing"
 	"time"
 
+	"job/application/exchangerate"
+	"job/domain/models"
+	"job/domain/repository/migrations"
+	"job/presentation/core/auth"
+	"job/presentation/core/middleware"
+	"job/presentation/core/mytime"
+	"job/presentation/core/validator"
+
 	"github.com/DATA-DOG/go-sqlmock"
 	"github.com/gorilla/mux"
+	"github.com/shopspring/decimal"
 )
 
-type mockLogger struct{}
-
-func (logger *mockLogger) Info(message string, source string) { log.Println(message) }
-
-func (logger *mockLogger) Error(message string, source string) { log.Println(message) }
-
-func (logger *mockLogger) Warning(message string, source string) { log.Println(message) }
-
-func (logger *mockLogger) Fatal(message string, source string) { log.Println(message) }
+func newLogger() *slog.Logger {
+	return slog.New(slog.NewTextHandler(log.Writer(), nil))
+}
 
-func newLogger() *mockLogger {
-	return new(mockLogger)
+// requireAdmin lets through the requests made with the key "secret".
+func requireAdmin(next http.HandlerFunc) http.HandlerFunc {
+	clients := map[string]auth.Client{auth.HashKey("secret"): {ID: "ops", Scopes: []string{auth.ScopeAdmin}}}
+	return middleware.NewAuthenticator(true, clients, nil).Require(auth.ScopeAdmin, next)
 }
 
 func Test_GetBalancePg_ShouldReturn_SuccessResult(t *testing.T) {
@@ -33,7 +43,7 @@ func Test_GetBalancePg_ShouldReturn_SuccessResult(t *testing.T) {
 	}
 	defer db.Close()
 
-	mock.ExpectQuery("^SELECT (.+) FROM balances WHERE").WithArgs(1).WillReturnRows(sqlmock.NewRows([]string{"id", "balance"}).AddRow(1, "100"))
+	mock.ExpectQuery("^SELECT (.+) FROM balances WHERE").WithArgs(1).WillReturnRows(sqlmock.NewRows([]string{"id", "balance", "currency"}).AddRow(1, "100", "RUB"))
 
 	env := &Environment{Balances: db, logger: newLogger()}
 
@@ -70,7 +80,7 @@ func Test_GetBalancePg_ShouldReturn_ErrorResultBalance1(t *testing.T) {
 	}
 	defer db.Close()
 
-	mock.ExpectQuery("^SELECT (.+) FROM balances WHERE").WithArgs(1).WillReturnRows(sqlmock.NewRows([]string{"id", "balance"}).AddRow(1, 100))
+	mock.ExpectQuery("^SELECT (.+) FROM balances WHERE").WithArgs(1).WillReturnRows(sqlmock.NewRows([]string{"id", "balance", "currency"}).AddRow(1, 100, "RUB"))
 
 	env := &Environment{Balances: db, logger: newLogger()}
 
@@ -109,7 +119,7 @@ func Test_GetBalancePg_ShouldReturn_ErrorResultBalance2(t *testing.T) {
 	}
 	defer db.Close()
 
-	mock.ExpectQuery("SELECT (.+) FROM balances WHERE").WithArgs(2).WillReturnRows(sqlmock.NewRows([]string{"id", "balance"}).AddRow(10, 100))
+	mock.ExpectQuery("SELECT (.+) FROM balances WHERE").WithArgs(2).WillReturnRows(sqlmock.NewRows([]string{"id", "balance", "currency"}).AddRow(10, 100, "RUB"))
 
 	env := &Environment{Balances: db, logger: newLogger()}
 
@@ -148,7 +158,7 @@ func Test_GetHistoryPg_ShouldReturn_SuccessResult(t *testing.T) {
 	}
 	defer db.Close()
 
-	mock.ExpectQuery("SELECT (.+) FROM transactions WHERE balance_id = (.+) ORDER BY (.+) LIMIT (.+) OFFSET (.+);").WithArgs(1).WillReturnRows(sqlmock.NewRows([]string{"id", "balance_id", "from_id", "amount", "reason", "type", "date"}).AddRow(1, "100", 0, "100", "Some", "income", time.Now()))
+	mock.ExpectQuery("SELECT (.+) FROM transactions WHERE balance_id = (.+) ORDER BY (.+) LIMIT (.+) OFFSET (.+);").WithArgs(1).WillReturnRows(sqlmock.NewRows([]string{"id", "balance_id", "from_id", "amount", "reason", "type", "date", "quote_id", "status", "transfer_id", "client_id", "currency"}).AddRow(1, "100", 0, "100", "Some", "income", time.Now(), nil, "completed", nil, nil, "RUB"))
 
 	env := &Environment{Balances: db, logger: newLogger()}
 
@@ -191,7 +201,7 @@ func Test_GetHistoryPg_ShouldReturn_ErrorResult(t *testing.T) {
 	}
 	defer db.Close()
 
-	mock.ExpectQuery("SELECT (.+) FROM transactions WHERE balance_id = (.+) ORDER BY (.+) LIMIT (.+) OFFSET (.+);").WithArgs(1).WillReturnRows(sqlmock.NewRows([]string{"id", "balance_id", "from_id", "amount", "reason", "type", "date"}).AddRow(1, "100", 0, "100", "Some", "income", time.Now()))
+	mock.ExpectQuery("SELECT (.+) FROM transactions WHERE balance_id = (.+) ORDER BY (.+) LIMIT (.+) OFFSET (.+);").WithArgs(1).WillReturnRows(sqlmock.NewRows([]string{"id", "balance_id", "from_id", "amount", "reason", "type", "date", "quote_id", "status", "transfer_id", "client_id", "currency"}).AddRow(1, "100", 0, "100", "Some", "income", time.Now(), nil, "completed", nil, nil, "RUB"))
 
 	env := &Environment{Balances: db, logger: newLogger()}
 
@@ -228,6 +238,33 @@ func Test_GetHistoryPg_ShouldReturn_ErrorResult(t *testing.T) {
 	}
 }
 
+func Test_GetHistoryPg_ShouldReturn_SuccessResultNullAmount(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer db.Close()
+
+	mock.ExpectQuery("SELECT (.+) FROM transactions WHERE balance_id = (.+) ORDER BY (.+) LIMIT (.+) OFFSET (.+);").WithArgs(1).WillReturnRows(sqlmock.NewRows([]string{"id", "balance_id", "from_id", "amount", "reason", "type", "date", "quote_id", "status", "transfer_id", "client_id", "currency"}).AddRow(1, "100", 0, nil, "Some", "income", time.Now(), nil, "completed", nil, nil, "RUB"))
+
+	env := &Environment{Balances: db, logger: newLogger()}
+
+	req, err := http.NewRequest("GET", "http://localhost:8080/balances/history/1?currency=RUB", nil)
+	if err != nil {
+		log.Println(err)
+		return
+	}
+	req = mux.SetURLVars(req, map[string]string{"id": "1"})
+
+	rr := httptest.NewRecorder()
+	handler := http.HandlerFunc(env.GetHistory)
+	handler.ServeHTTP(rr, req)
+	if rr.Code != http.StatusOK {
+		log.Printf("Expected 200 for a row without amount, but got %d %s\n", rr.Code, rr.Body.String())
+		t.Fatal(rr.Code)
+	}
+}
+
 func Test_IncomeTransactionPg_ShouldReturn_SuccessResult(t *testing.T) {
 	db, mock, err := sqlmock.New()
 	if err != nil {
@@ -235,12 +272,13 @@ func Test_IncomeTransactionPg_ShouldReturn_SuccessResult(t *testing.T) {
 	}
 	defer db.Close()
 
-	mock.ExpectQuery("INSERT INTO balances (.+) VALUES (.+) ON CONFLICT (id) DO UPDATE SET (.+);").WithArgs(2)
+	mock.ExpectExec("INSERT INTO balances").WithArgs(1, "200.00", "RUB").WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectExec("INSERT INTO transactions").WillReturnResult(sqlmock.NewResult(1, 1))
 
 	env := &Environment{Balances: db, logger: newLogger()}
 
 	var jsonStr = []byte(`{"toId": 1, "amount":"200", "reason":"Some"}`)
-	
+
 	req, err := http.NewRequest("POST", "http://localhost:8080/balances/income", bytes.NewBuffer(jsonStr))
 	if err != nil {
 		return
@@ -256,7 +294,6 @@ func Test_IncomeTransactionPg_ShouldReturn_SuccessResult(t *testing.T) {
 	}
 }
 
-
 func Test_IncomeTransactionPg_ShouldReturn_ErrorResult(t *testing.T) {
 	db, mock, err := sqlmock.New()
 	if err != nil {
@@ -269,7 +306,121 @@ func Test_IncomeTransactionPg_ShouldReturn_ErrorResult(t *testing.T) {
 	env := &Environment{Balances: db, logger: newLogger()}
 
 	var jsonStr = []byte(`{"toId": "1", "amount":"200", "reason":"Some"}`)
-	
+
+	req, err := http.NewRequest("POST", "http://localhost:8080/balances/income", bytes.NewBuffer(jsonStr))
+	if err != nil {
+		log.Println(err)
+		return
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	rr := httptest.NewRecorder()
+	handler := http.HandlerFunc(env.IncomeTransaction)
+	handler.ServeHTTP(rr, req)
+	if rr.Code != http.StatusBadRequest {
+		log.Printf("Expected 400, but got %d\n", rr.Code)
+		t.Fatal(rr.Code)
+	}
+}
+
+func Test_IncomeTransactionPg_ShouldReturn_ErrorResultUnknownField(t *testing.T) {
+	db, _, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer db.Close()
+
+	env := &Environment{Balances: db, logger: newLogger()}
+
+	var jsonStr = []byte(`{"toId": 1, "amount":"200", "reason":"Some", "type":"outcome"}`)
+
+	req, err := http.NewRequest("POST", "http://localhost:8080/balances/income", bytes.NewBuffer(jsonStr))
+	if err != nil {
+		log.Println(err)
+		return
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	rr := httptest.NewRecorder()
+	handler := http.HandlerFunc(env.IncomeTransaction)
+	handler.ServeHTTP(rr, req)
+	if rr.Code != http.StatusBadRequest {
+		log.Printf("Expected 400, but got %d\n", rr.Code)
+		t.Fatal(rr.Code)
+	}
+
+	expected := `"name":"type"`
+	if body := rr.Body.String(); !strings.Contains(body, expected) {
+		log.Printf("Expected problem for field type, but got %s\n", body)
+		t.Fatal(body)
+	}
+}
+
+func Test_IncomeTransactionPg_ShouldReturn_ErrorResultTrailingData(t *testing.T) {
+	db, _, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer db.Close()
+
+	env := &Environment{Balances: db, logger: newLogger()}
+
+	var jsonStr = []byte(`{"toId": 1, "amount":"200", "reason":"Some"}{"toId": 2}`)
+
+	req, err := http.NewRequest("POST", "http://localhost:8080/balances/income", bytes.NewBuffer(jsonStr))
+	if err != nil {
+		log.Println(err)
+		return
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	rr := httptest.NewRecorder()
+	handler := http.HandlerFunc(env.IncomeTransaction)
+	handler.ServeHTTP(rr, req)
+	if rr.Code != http.StatusBadRequest {
+		log.Printf("Expected 400, but got %d\n", rr.Code)
+		t.Fatal(rr.Code)
+	}
+}
+
+func Test_IncomeTransactionPg_ShouldReturn_ErrorResultContentType(t *testing.T) {
+	db, _, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer db.Close()
+
+	env := &Environment{Balances: db, logger: newLogger()}
+
+	var jsonStr = []byte(`{"toId": 1, "amount":"200", "reason":"Some"}`)
+
+	req, err := http.NewRequest("POST", "http://localhost:8080/balances/income", bytes.NewBuffer(jsonStr))
+	if err != nil {
+		log.Println(err)
+		return
+	}
+	req.Header.Set("Content-Type", "text/plain")
+
+	rr := httptest.NewRecorder()
+	handler := http.HandlerFunc(env.IncomeTransaction)
+	handler.ServeHTTP(rr, req)
+	if rr.Code != http.StatusUnsupportedMediaType {
+		log.Printf("Expected 415, but got %d\n", rr.Code)
+		t.Fatal(rr.Code)
+	}
+}
+
+func Test_IncomeTransactionPg_ShouldReturn_ErrorResultBodyTooLarge(t *testing.T) {
+	db, _, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer db.Close()
+
+	env := &Environment{Balances: db, MaxBodyBytes: 16, logger: newLogger()}
+
+	var jsonStr = []byte(`{"toId": 1, "amount":"200", "reason":"Some"}`)
+
 	req, err := http.NewRequest("POST", "http://localhost:8080/balances/income", bytes.NewBuffer(jsonStr))
 	if err != nil {
 		log.Println(err)
@@ -280,8 +431,770 @@ func Test_IncomeTransactionPg_ShouldReturn_ErrorResult(t *testing.T) {
 	rr := httptest.NewRecorder()
 	handler := http.HandlerFunc(env.IncomeTransaction)
 	handler.ServeHTTP(rr, req)
+	if rr.Code != http.StatusRequestEntityTooLarge {
+		log.Printf("Expected 413, but got %d\n", rr.Code)
+		t.Fatal(rr.Code)
+	}
+}
+
+func Test_TransferTransactionPg_ShouldReturn_ErrorResultFieldPath(t *testing.T) {
+	db, _, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer db.Close()
+
+	env := &Environment{Balances: db, logger: newLogger()}
+
+	var jsonStr = []byte(`{"fromId": 1, "toId": "2", "amount":"200", "reason":"Some"}`)
+
+	req, err := http.NewRequest("POST", "http://localhost:8080/balances/transfer", bytes.NewBuffer(jsonStr))
+	if err != nil {
+		log.Println(err)
+		return
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	rr := httptest.NewRecorder()
+	handler := http.HandlerFunc(env.TransferTransaction)
+	handler.ServeHTTP(rr, req)
+	if rr.Code != http.StatusBadRequest {
+		log.Printf("Expected 400, but got %d\n", rr.Code)
+		t.Fatal(rr.Code)
+	}
+
+	expected := `"name":"toId"`
+	if body := rr.Body.String(); !strings.Contains(body, expected) {
+		log.Printf("Expected problem for field toId, but got %s\n", body)
+		t.Fatal(body)
+	}
+}
+
+func Test_IncomeTransactionPg_ShouldReturn_SuccessResultNumberAmount(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer db.Close()
+
+	mock.ExpectExec("INSERT INTO balances").WithArgs(1, "200.50", "RUB").WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectExec("INSERT INTO transactions").WillReturnResult(sqlmock.NewResult(1, 1))
+
+	env := &Environment{Balances: db, logger: newLogger()}
+
+	var jsonStr = []byte(`{"toId": 1, "amount": 200.5, "reason":"Some"}`)
+
+	req, err := http.NewRequest("POST", "http://localhost:8080/balances/income", bytes.NewBuffer(jsonStr))
+	if err != nil {
+		log.Println(err)
+		return
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	rr := httptest.NewRecorder()
+	handler := http.HandlerFunc(env.IncomeTransaction)
+	handler.ServeHTTP(rr, req)
+	if rr.Code != http.StatusOK {
+		log.Printf("Expected 200, but got %d\n", rr.Code)
+		t.Fatal(rr.Code)
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func Test_IncomeTransactionPg_ShouldReturn_ErrorResultAmount(t *testing.T) {
+	db, _, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer db.Close()
+
+	env := &Environment{Balances: db, logger: newLogger()}
+
+	var jsonStr = []byte(`{"toId": 1, "amount":"abc", "reason":"Some"}`)
+
+	req, err := http.NewRequest("POST", "http://localhost:8080/balances/income", bytes.NewBuffer(jsonStr))
+	if err != nil {
+		log.Println(err)
+		return
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	rr := httptest.NewRecorder()
+	handler := http.HandlerFunc(env.IncomeTransaction)
+	handler.ServeHTTP(rr, req)
+	if rr.Code != http.StatusBadRequest {
+		log.Printf("Expected 400, but got %d\n", rr.Code)
+		t.Fatal(rr.Code)
+	}
+
+	expected := `"name":"amount"`
+	if body := rr.Body.String(); !strings.Contains(body, expected) {
+		log.Printf("Expected problem for field amount, but got %s\n", body)
+		t.Fatal(body)
+	}
+}
+
+func Test_OutcomeTransactionPg_ShouldReturn_ErrorResultAmountScale(t *testing.T) {
+	db, _, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer db.Close()
+
+	env := &Environment{Balances: db, logger: newLogger()}
+
+	var jsonStr = []byte(`{"fromId": 1, "amount":"10.001", "reason":"Some"}`)
+
+	req, err := http.NewRequest("POST", "http://localhost:8080/balances/outcome", bytes.NewBuffer(jsonStr))
+	if err != nil {
+		log.Println(err)
+		return
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	rr := httptest.NewRecorder()
+	handler := http.HandlerFunc(env.OutcomeTransaction)
+	handler.ServeHTTP(rr, req)
+	if rr.Code != http.StatusBadRequest {
+		log.Printf("Expected 400, but got %d\n", rr.Code)
+		t.Fatal(rr.Code)
+	}
+
+	expected := `"name":"amount"`
+	if body := rr.Body.String(); !strings.Contains(body, expected) {
+		log.Printf("Expected problem for field amount, but got %s\n", body)
+		t.Fatal(body)
+	}
+}
+
+func Test_OutcomeTransactionPg_ShouldReturn_ErrorResultNegativeAmount(t *testing.T) {
+	db, _, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer db.Close()
+
+	env := &Environment{Balances: db, logger: newLogger()}
+
+	var jsonStr = []byte(`{"fromId": 1, "amount": {"amount": "-5", "currency": "RUB"}, "reason":"Some"}`)
+
+	req, err := http.NewRequest("POST", "http://localhost:8080/balances/outcome", bytes.NewBuffer(jsonStr))
+	if err != nil {
+		log.Println(err)
+		return
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	rr := httptest.NewRecorder()
+	handler := http.HandlerFunc(env.OutcomeTransaction)
+	handler.ServeHTTP(rr, req)
 	if rr.Code != http.StatusBadRequest {
 		log.Printf("Expected 400, but got %d\n", rr.Code)
 		t.Fatal(rr.Code)
 	}
 }
+
+func Test_GetBalancePg_ShouldReturn_ErrorResultCurrency(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer db.Close()
+
+	env := &Environment{Balances: db, logger: newLogger()}
+
+	vars := map[string]string{
+		"id": "1",
+	}
+
+	req, err := http.NewRequest("GET", "http://localhost:8080/balances/{id}?currency=ABC", nil)
+	if err != nil {
+		log.Println(err)
+		return
+	}
+
+	req = mux.SetURLVars(req, vars)
+
+	rr := httptest.NewRecorder()
+	handler := http.HandlerFunc(env.GetBalance)
+	handler.ServeHTTP(rr, req)
+	if rr.Code != http.StatusBadRequest {
+		log.Printf("Expected 400, but got %d\n", rr.Code)
+		t.Fatal(rr.Code)
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func quoteRows(expiresAt time.Time, usedAt interface{}) *sqlmock.Rows {
+	return sqlmock.NewRows([]string{"id", "source_amount", "source_currency", "target_amount", "target_currency", "market_rate", "spread", "rate", "rounding", "override", "created_at", "expires_at", "used_at"}).
+		AddRow("q1", "10", "USD", "750", "RUB", "75", "0", "75", "half-even", false, time.Now(), expiresAt, usedAt)
+}
+
+func Test_TransferTransactionPg_ShouldReturn_SuccessResultQuote(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer db.Close()
+
+	mock.ExpectQuery("SELECT (.+) FROM quotes WHERE").WithArgs("q1").WillReturnRows(quoteRows(time.Now().Add(time.Minute), nil))
+	mock.ExpectQuery("SELECT (.+) FROM balances WHERE").WithArgs(1).WillReturnRows(sqlmock.NewRows([]string{"id", "balance", "currency"}).AddRow(1, "1000", "USD"))
+	mock.ExpectBegin()
+	mock.ExpectExec("UPDATE quotes SET used_at").WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec("UPDATE balances SET balance").WithArgs("10.00", 1, "USD").WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec("INSERT INTO transactions").WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectExec("INSERT INTO balances").WithArgs(2, "750.00", "RUB").WillReturnResult(sqlmock.NewResult(2, 1))
+	mock.ExpectExec("INSERT INTO transactions").WillReturnResult(sqlmock.NewResult(2, 1))
+	mock.ExpectCommit()
+
+	env := &Environment{Balances: db, logger: newLogger()}
+
+	var jsonStr = []byte(`{"fromId": 1, "toId": 2, "reason":"Some", "quoteId": "q1"}`)
+
+	req, err := http.NewRequest("POST", "http://localhost:8080/balances/transfer", bytes.NewBuffer(jsonStr))
+	if err != nil {
+		log.Println(err)
+		return
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	rr := httptest.NewRecorder()
+	handler := http.HandlerFunc(env.TransferTransaction)
+	handler.ServeHTTP(rr, req)
+	if rr.Code != http.StatusOK {
+		log.Printf("Expected 200, but got %d\n", rr.Code)
+		t.Fatal(rr.Code)
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func Test_TransferTransactionPg_ShouldReturn_ErrorResultQuote(t *testing.T) {
+	cases := []struct {
+		expiresAt time.Time
+		usedAt    interface{}
+		status    int
+	}{
+		{time.Now().Add(-time.Minute), nil, http.StatusGone},
+		{time.Now().Add(time.Minute), time.Now(), http.StatusConflict},
+	}
+
+	for _, c := range cases {
+		db, mock, err := sqlmock.New()
+		if err != nil {
+			t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+		}
+		defer db.Close()
+
+		mock.ExpectQuery("SELECT (.+) FROM quotes WHERE").WithArgs("q1").WillReturnRows(quoteRows(c.expiresAt, c.usedAt))
+
+		env := &Environment{Balances: db, logger: newLogger()}
+
+		var jsonStr = []byte(`{"fromId": 1, "toId": 2, "reason":"Some", "quoteId": "q1"}`)
+
+		req, err := http.NewRequest("POST", "http://localhost:8080/balances/transfer", bytes.NewBuffer(jsonStr))
+		if err != nil {
+			log.Println(err)
+			return
+		}
+		req.Header.Set("Content-Type", "application/json")
+
+		rr := httptest.NewRecorder()
+		handler := http.HandlerFunc(env.TransferTransaction)
+		handler.ServeHTTP(rr, req)
+		if rr.Code != c.status {
+			log.Printf("Expected %d, but got %d\n", c.status, rr.Code)
+			t.Fatal(rr.Code)
+		}
+	}
+}
+
+func Test_TransferTransactionPg_ShouldReturn_ErrorResultQuoteCurrency(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer db.Close()
+
+	rows := sqlmock.NewRows([]string{"id", "source_amount", "source_currency", "target_amount", "target_currency", "market_rate", "spread", "rate", "rounding", "override", "created_at", "expires_at", "used_at"}).
+		AddRow("q1", "10", "USD", "9", "EUR", "0.9", "0", "0.9", "half-even", false, time.Now(), time.Now().Add(time.Minute), nil)
+	mock.ExpectQuery("SELECT (.+) FROM quotes WHERE").WithArgs("q1").WillReturnRows(rows)
+
+	env := &Environment{Balances: db, logger: newLogger()}
+
+	var jsonStr = []byte(`{"fromId": 1, "toId": 2, "reason":"Some", "quoteId": "q1"}`)
+
+	req, err := http.NewRequest("POST", "http://localhost:8080/balances/transfer", bytes.NewBuffer(jsonStr))
+	if err != nil {
+		log.Println(err)
+		return
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	rr := httptest.NewRecorder()
+	handler := http.HandlerFunc(env.TransferTransaction)
+	handler.ServeHTTP(rr, req)
+	if rr.Code != http.StatusBadRequest || !strings.Contains(rr.Body.String(), "Quote must convert from or to RUB!") {
+		log.Printf("Expected 400 for a quote without the base currency, but got %d %s\n", rr.Code, rr.Body.String())
+		t.Fatal(rr.Code)
+	}
+}
+
+func Test_IncomeTransactionPg_ShouldReturn_ErrorResultBalanceCurrency(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer db.Close()
+
+	mock.ExpectExec("INSERT INTO balances").WithArgs(1, "200.00", "RUB").WillReturnResult(sqlmock.NewResult(0, 0))
+
+	env := &Environment{Balances: db, logger: newLogger()}
+
+	var jsonStr = []byte(`{"toId": 1, "amount":"200", "reason":"Some"}`)
+
+	req, err := http.NewRequest("POST", "http://localhost:8080/balances/income", bytes.NewBuffer(jsonStr))
+	if err != nil {
+		log.Println(err)
+		return
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	rr := httptest.NewRecorder()
+	handler := http.HandlerFunc(env.IncomeTransaction)
+	handler.ServeHTTP(rr, req)
+	if rr.Code != http.StatusConflict {
+		log.Printf("Expected 409 for a balance in another currency, but got %d %s\n", rr.Code, rr.Body.String())
+		t.Fatal(rr.Code)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func Test_TransferTransactionPg_ShouldReturn_ErrorResultQuoteLookup(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer db.Close()
+
+	mock.ExpectQuery("SELECT (.+) FROM quotes WHERE").WithArgs("q1").WillReturnError(errors.New("connection reset"))
+
+	env := &Environment{Balances: db, logger: newLogger()}
+
+	var jsonStr = []byte(`{"fromId": 1, "toId": 2, "reason":"Some", "quoteId": "q1"}`)
+
+	req, err := http.NewRequest("POST", "http://localhost:8080/balances/transfer", bytes.NewBuffer(jsonStr))
+	if err != nil {
+		log.Println(err)
+		return
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	rr := httptest.NewRecorder()
+	handler := http.HandlerFunc(env.TransferTransaction)
+	handler.ServeHTTP(rr, req)
+	if rr.Code != http.StatusInternalServerError || strings.Contains(rr.Body.String(), "connection reset") {
+		log.Printf("Expected 500 without details, but got %d %s\n", rr.Code, rr.Body.String())
+		t.Fatal(rr.Code)
+	}
+}
+
+func Test_PutRateOverride_ShouldReturn_ErrorResultUnauthorized(t *testing.T) {
+	env := &Environment{logger: newLogger()}
+
+	var jsonStr = []byte(`{"base": "RUB", "currency": "USD", "rate": "0.02", "expiresAt": "2999-01-01 00:00:00"}`)
+
+	req, err := http.NewRequest("PUT", "http://localhost:8080/admin/rates/overrides", bytes.NewBuffer(jsonStr))
+	if err != nil {
+		log.Println(err)
+		return
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Authorization", "Bearer wrong")
+
+	rr := httptest.NewRecorder()
+	handler := requireAdmin(env.PutRateOverride)
+	handler.ServeHTTP(rr, req)
+	if rr.Code != http.StatusUnauthorized {
+		log.Printf("Expected 401, but got %d\n", rr.Code)
+		t.Fatal(rr.Code)
+	}
+}
+
+func Test_GetBalancePg_ShouldReturn_SuccessResultOverride(t *testing.T) {
+	store, storeMock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer store.Close()
+	storeMock.ExpectExec("INSERT INTO rate_overrides").WithArgs("RUB", "USD", sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg()).WillReturnResult(sqlmock.NewResult(0, 1))
+
+	env := &Environment{Balances: store, logger: newLogger()}
+
+	var jsonStr = []byte(`{"base": "RUB", "currency": "USD", "rate": "0.02", "expiresAt": "2999-01-01 00:00:00"}`)
+
+	req, err := http.NewRequest("PUT", "http://localhost:8080/admin/rates/overrides", bytes.NewBuffer(jsonStr))
+	if err != nil {
+		log.Println(err)
+		return
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Authorization", "Bearer secret")
+
+	rr := httptest.NewRecorder()
+	handler := requireAdmin(env.PutRateOverride)
+	handler.ServeHTTP(rr, req)
+	if rr.Code != http.StatusOK {
+		log.Printf("Expected 200, but got %d\n", rr.Code)
+		t.Fatal(rr.Code)
+	}
+	defer exchangerate.DeleteOverride("RUB", "USD")
+	if err := storeMock.ExpectationsWereMet(); err != nil {
+		t.Fatal(err)
+	}
+
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer db.Close()
+
+	mock.ExpectQuery("^SELECT (.+) FROM balances WHERE").WithArgs(1).WillReturnRows(sqlmock.NewRows([]string{"id", "balance", "currency"}).AddRow(1, "100", "RUB"))
+	env.Balances = db
+
+	req, err = http.NewRequest("GET", "http://localhost:8080/balances/{id}?currency=USD", nil)
+	if err != nil {
+		log.Println(err)
+		return
+	}
+	req = mux.SetURLVars(req, map[string]string{"id": "1"})
+
+	rr = httptest.NewRecorder()
+	http.HandlerFunc(env.GetBalance).ServeHTTP(rr, req)
+	if rr.Code != http.StatusOK {
+		log.Printf("Expected 200, but got %d\n", rr.Code)
+		t.Fatal(rr.Code)
+	}
+
+	expected := `"conversions":[{"amount":{"amount":"2.00","currency":"USD"},"marketRate":"0.02","spread":"0","rate":"0.02","rounding":"half-even","override":true,"ratedAt":`
+	if body := rr.Body.String(); !strings.Contains(body, expected) {
+		log.Printf("Expected %s, but got %s\n", expected, body)
+		t.Fatal(body)
+	}
+}
+
+func Test_GetHistoryPg_ShouldReturn_SuccessResultConversions(t *testing.T) {
+	expiresAt := time.Now().Add(time.Hour)
+	for _, code := range []string{"USD", "EUR"} {
+		exchangerate.SetOverride(models.RateOverride{Base: "RUB", Currency: code, Rate: decimal.RequireFromString("0.01"), CreatedAt: &mytime.MyTime{Time: &expiresAt}, ExpiresAt: &mytime.MyTime{Time: &expiresAt}})
+		defer exchangerate.DeleteOverride("RUB", code)
+	}
+
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer db.Close()
+
+	mock.ExpectQuery("SELECT (.+) FROM transactions WHERE balance_id = (.+) ORDER BY (.+) LIMIT (.+) OFFSET (.+);").WithArgs(1).WillReturnRows(sqlmock.NewRows([]string{"id", "balance_id", "from_id", "amount", "reason", "type", "date", "quote_id", "status", "transfer_id", "client_id", "currency"}).AddRow(1, "100", 0, "100", "Some", "income", time.Now(), nil, "completed", nil, nil, "RUB"))
+
+	env := &Environment{Balances: db, logger: newLogger()}
+
+	req, err := http.NewRequest("GET", "http://localhost:8080/balances/history/{id}?currency=usd,EUR,RUB", nil)
+	if err != nil {
+		log.Println(err)
+		return
+	}
+	req = mux.SetURLVars(req, map[string]string{"id": "1"})
+
+	rr := httptest.NewRecorder()
+	http.HandlerFunc(env.GetHistory).ServeHTTP(rr, req)
+	if rr.Code != http.StatusOK {
+		log.Printf("Expected 200, but got %d\n", rr.Code)
+		t.Fatal(rr.Code)
+	}
+
+	var transactions []models.Transaction
+	if err := json.Unmarshal(rr.Body.Bytes(), &transactions); err != nil {
+		t.Fatal(err)
+	}
+	conversions := transactions[0].Conversions
+	if len(conversions) != 2 || conversions[0].Amount.String() != "1.00 USD" || conversions[1].Amount.String() != "1.00 EUR" {
+		log.Printf("Expected 1.00 USD and 1.00 EUR, but got %v\n", conversions)
+		t.Fatal(conversions)
+	}
+}
+
+func Test_IncomeTransactionPg_ShouldReturn_ErrorResultLimit(t *testing.T) {
+	limits, err := validator.NewLimits("100", "", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	env := &Environment{logger: newLogger()}
+	env.SetLimits(limits)
+
+	var jsonStr = []byte(`{"toId": 1, "amount":"200", "reason":"Some"}`)
+
+	req, err := http.NewRequest("POST", "http://localhost:8080/balances/income", bytes.NewBuffer(jsonStr))
+	if err != nil {
+		log.Println(err)
+		return
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	rr := httptest.NewRecorder()
+	handler := http.HandlerFunc(env.IncomeTransaction)
+	handler.ServeHTTP(rr, req)
+	if rr.Code != http.StatusBadRequest {
+		log.Printf("Expected 400, but got %d\n", rr.Code)
+		t.Fatal(rr.Code)
+	}
+	if body := rr.Body.String(); !strings.Contains(body, "Amount must not exceed 100 RUB!") {
+		log.Printf("Expected limit error, but got %s\n", body)
+		t.Fatal(body)
+	}
+}
+
+func Test_GetBalancePg_ShouldReturn_ErrorResultDatabase(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer db.Close()
+
+	mock.ExpectQuery("^SELECT (.+) FROM balances WHERE").WithArgs(1).WillReturnError(errors.New("connection refused"))
+
+	env := &Environment{Balances: db, logger: newLogger()}
+
+	req, err := http.NewRequest("GET", "http://localhost:8080/balances/{id}", nil)
+	if err != nil {
+		log.Println(err)
+		return
+	}
+	req = mux.SetURLVars(req, map[string]string{"id": "1"})
+
+	rr := httptest.NewRecorder()
+	handler := http.HandlerFunc(env.GetBalance)
+	handler.ServeHTTP(rr, req)
+	if rr.Code != http.StatusInternalServerError {
+		log.Printf("Expected 500, but got %d\n", rr.Code)
+		t.Fatal(rr.Code)
+	}
+	if strings.Contains(rr.Body.String(), "connection refused") {
+		log.Printf("Expected no database details, but got %s\n", rr.Body.String())
+		t.Fatal(rr.Body.String())
+	}
+}
+
+func Test_GetReadiness_ShouldReturn_SuccessResult(t *testing.T) {
+	db, mock, err := sqlmock.New(sqlmock.MonitorPingsOption(true))
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer db.Close()
+
+	migrator, err := migrations.New(db, "RUB")
+	if err != nil {
+		t.Fatal(err)
+	}
+	mock.ExpectPing()
+	mock.ExpectQuery("to_regclass").WillReturnRows(sqlmock.NewRows([]string{"exists"}).AddRow(true))
+	mock.ExpectQuery("FROM schema_migrations").WillReturnRows(sqlmock.NewRows([]string{"max"}).AddRow(migrator.Latest()))
+
+	env := &Environment{Balances: db, logger: newLogger()}
+	env.SetMigrator(migrator).SetHealth(time.Second, 0)
+
+	rr := httptest.NewRecorder()
+	env.GetReadiness(rr, httptest.NewRequest("GET", "/readyz", nil))
+	if rr.Code != http.StatusOK {
+		log.Printf("Expected 200, but got %d %s\n", rr.Code, rr.Body.String())
+		t.Fatal(rr.Code)
+	}
+	var health models.Health
+	if err := json.Unmarshal(rr.Body.Bytes(), &health); err != nil {
+		t.Fatal(err)
+	}
+	if health.Checks["database"].Status != "ok" || health.Checks["migrations"].Status != "ok" {
+		log.Printf("Expected database and migrations checks ok, but got %v\n", health.Checks)
+		t.Fatal(health.Checks)
+	}
+}
+
+func Test_GetReadiness_ShouldReturn_SuccessResultNewerSchema(t *testing.T) {
+	db, mock, err := sqlmock.New(sqlmock.MonitorPingsOption(true))
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer db.Close()
+
+	migrator, err := migrations.New(db, "RUB")
+	if err != nil {
+		t.Fatal(err)
+	}
+	mock.ExpectPing()
+	mock.ExpectQuery("to_regclass").WillReturnRows(sqlmock.NewRows([]string{"exists"}).AddRow(true))
+	mock.ExpectQuery("FROM schema_migrations").WillReturnRows(sqlmock.NewRows([]string{"max"}).AddRow(migrator.Latest() + 1))
+
+	env := &Environment{Balances: db, logger: newLogger()}
+	env.SetMigrator(migrator).SetHealth(time.Second, time.Hour)
+
+	rr := httptest.NewRecorder()
+	env.GetReadiness(rr, httptest.NewRequest("GET", "/readyz", nil))
+	if rr.Code != http.StatusOK {
+		log.Printf("Expected 200 with a newer schema, but got %d %s\n", rr.Code, rr.Body.String())
+		t.Fatal(rr.Code)
+	}
+	if !strings.Contains(rr.Body.String(), `"rates":{"status":"ok"`) {
+		log.Printf("Expected the rates check ok, but got %s\n", rr.Body.String())
+		t.Fatal(rr.Body.String())
+	}
+}
+
+func Test_GetReadiness_ShouldReturn_ErrorResultDraining(t *testing.T) {
+	db, mock, err := sqlmock.New(sqlmock.MonitorPingsOption(true))
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer db.Close()
+	mock.ExpectPing()
+
+	env := &Environment{Balances: db, logger: newLogger()}
+	env.Drain()
+
+	rr := httptest.NewRecorder()
+	env.GetReadiness(rr, httptest.NewRequest("GET", "/readyz", nil))
+	if rr.Code != http.StatusServiceUnavailable {
+		log.Printf("Expected 503, but got %d\n", rr.Code)
+		t.Fatal(rr.Code)
+	}
+	if !strings.Contains(rr.Body.String(), `"shutdown":{"status":"fail"`) {
+		log.Printf("Expected the shutdown check to fail, but got %s\n", rr.Body.String())
+		t.Fatal(rr.Body.String())
+	}
+}
+
+func Test_IncomeTransactionPg_ShouldReturn_SuccessResultClient(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer db.Close()
+
+	mock.ExpectExec("INSERT INTO balances").WithArgs(1, "200.00", "RUB").WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectExec("INSERT INTO transactions").
+		WithArgs(1, 0, "200.00", "RUB", "Some", "income", sqlmock.AnyArg(), nil, nil, "partner").
+		WillReturnResult(sqlmock.NewResult(1, 1))
+
+	env := &Environment{Balances: db, logger: newLogger()}
+
+	var jsonStr = []byte(`{"toId": 1, "amount": "200", "reason":"Some"}`)
+
+	req, err := http.NewRequest("POST", "http://localhost:8080/balances/income", bytes.NewBuffer(jsonStr))
+	if err != nil {
+		log.Println(err)
+		return
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req = req.WithContext(auth.NewContext(req.Context(), auth.Client{ID: "partner", Scopes: []string{auth.ScopeIncomeWrite}}))
+
+	rr := httptest.NewRecorder()
+	http.HandlerFunc(env.IncomeTransaction).ServeHTTP(rr, req)
+	if rr.Code != http.StatusOK {
+		log.Printf("Expected 200, but got %d %s\n", rr.Code, rr.Body.String())
+		t.Fatal(rr.Code)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		log.Printf("Expected the client id recorded, but got %v\n", err)
+		t.Fatal(err)
+	}
+}
+
+func Test_GetBalancePg_ShouldReturn_ErrorResultOwner(t *testing.T) {
+	env := &Environment{logger: newLogger()}
+	user := auth.Client{ID: "jwt:user-7", Scopes: []string{auth.ScopeBalancesRead}, Balances: []int64{7}}
+
+	req, err := http.NewRequest("GET", "http://localhost:8080/balances/{id}", nil)
+	if err != nil {
+		log.Println(err)
+		return
+	}
+	req = mux.SetURLVars(req, map[string]string{"id": "8"})
+	req = req.WithContext(auth.NewContext(req.Context(), user))
+
+	rr := httptest.NewRecorder()
+	http.HandlerFunc(env.GetBalance).ServeHTTP(rr, req)
+	if rr.Code != http.StatusForbidden {
+		log.Printf("Expected 403, but got %d\n", rr.Code)
+		t.Fatal(rr.Code)
+	}
+
+	var jsonStr = []byte(`{"fromId": 8, "toId": 7, "amount": "10", "reason":"Some"}`)
+	req, err = http.NewRequest("POST", "http://localhost:8080/balances/transfer", bytes.NewBuffer(jsonStr))
+	if err != nil {
+		log.Println(err)
+		return
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req = req.WithContext(auth.NewContext(req.Context(), user))
+
+	rr = httptest.NewRecorder()
+	http.HandlerFunc(env.TransferTransaction).ServeHTTP(rr, req)
+	if rr.Code != http.StatusForbidden {
+		log.Printf("Expected 403, but got %d\n", rr.Code)
+		t.Fatal(rr.Code)
+	}
+}
+
+func Test_CreateQuote_ShouldReturn_ErrorResultAmount(t *testing.T) {
+	env := &Environment{logger: newLogger()}
+
+	var jsonStr = []byte(`{"amount": "0", "currency": "USD"}`)
+
+	req, err := http.NewRequest("POST", "http://localhost:8080/quotes", bytes.NewBuffer(jsonStr))
+	if err != nil {
+		log.Println(err)
+		return
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	rr := httptest.NewRecorder()
+	handler := http.HandlerFunc(env.CreateQuote)
+	handler.ServeHTTP(rr, req)
+	if rr.Code != http.StatusBadRequest || !strings.Contains(rr.Body.String(), `"name":"amount"`) {
+		log.Printf("Expected 400 for the amount field, but got %d %s\n", rr.Code, rr.Body.String())
+		t.Fatal(rr.Body.String())
+	}
+}
+
+func Test_Close_ShouldReturn_ErrorResultDeadline(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	mock.ExpectClose()
+
+	env := &Environment{Balances: db, logger: newLogger()}
+	done := env.beginOperation()
+	defer done()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	if err := env.Close(ctx); err == nil {
+		log.Printf("Expected an error for the operation still running\n")
+		t.Fatal(err)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		log.Printf("Expected the pool closed at the deadline, but got %v\n", err)
+		t.Fatal(err)
+	}
+}
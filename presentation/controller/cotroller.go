@@ -3,23 +3,26 @@ package controller
 import (
 	"encoding/json"
 	"errors"
-	"fmt"
 	"log"
 	"net/http"
 	"strconv"
+	"time"
 
 	"job/application/exchangerate"
 
+	"job/domain/money"
 	"job/domain/repository"
 
-	"github.com/jimlawless/whereami"
-
+	"job/presentation/core/auth"
 	"job/presentation/core/jsonint"
+	"job/presentation/core/logger"
+	"job/presentation/core/metrics"
 	"job/presentation/core/rfc7807"
 
 	"job/presentation/core/validator"
 
 	"github.com/gorilla/mux"
+	"github.com/segmentio/ksuid"
 )
 
 func (env *Environment) GetBalance(w http.ResponseWriter, r *http.Request) {
@@ -34,7 +37,7 @@ func (env *Environment) GetBalance(w http.ResponseWriter, r *http.Request) {
 			SetType("business").
 			SetStatus(http.StatusBadRequest)
 		err = problem.Write(w)
-		env.logger.Info(errStr, whereami.WhereAmI())
+		logger.FromContext(r.Context()).Info(errStr)
 		if err != nil {
 			return
 		}
@@ -45,22 +48,25 @@ func (env *Environment) GetBalance(w http.ResponseWriter, r *http.Request) {
 			AppendError("Id", err.Error()).
 			SetType("business").
 			SetStatus(http.StatusBadRequest)
-		env.logger.Info(err.Error(), whereami.WhereAmI())
+		logger.FromContext(r.Context()).Info(err.Error())
 		err = problem.Write(w)
 		if err != nil {
 			return
 		}
 		return
 	}
+	logger.Annotate(ctx, "BalanceID", id)
+	if !owns(w, r, "Id", id) {
+		return
+	}
 
-	user, err := repository.GetBalancePg(ctx, env.Balances, int64(id))
-	if user.ID == nil {
-		err = errors.New("Have no balance with that id!")
+	currencies := validator.SplitCurrencies(r.URL.Query().Get("currency"))
+	if err := validator.ValidateCurrencies(ctx, currencies); err != nil {
 		problem := rfc7807.NewProblem().
-			AppendError("Id", err.Error()).
+			AppendError("currency", err.Error()).
 			SetType("business").
 			SetStatus(http.StatusBadRequest)
-		env.logger.Info(err.Error(), whereami.WhereAmI())
+		logger.FromContext(r.Context()).Info(err.Error())
 		err = problem.Write(w)
 		if err != nil {
 			return
@@ -68,26 +74,43 @@ func (env *Environment) GetBalance(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	user, err := repository.GetBalancePg(ctx, env.Balances, int64(id))
 	if err != nil {
-		log.Println(err)
-		env.logger.Error(err.Error(), whereami.WhereAmI())
+		problem := rfc7807.NewProblem().
+			AppendError("Server", "Internal server error!").
+			SetType("server").
+			SetStatus(http.StatusInternalServerError)
+		logger.FromContext(r.Context()).Error(err.Error())
+		err = problem.Write(w)
+		if err != nil {
+			return
+		}
 		return
 	}
 
-	currency := "RUB"
-	value := user.Amount
+	if user.ID == nil {
+		err = errors.New("Have no balance with that id!")
+		problem := rfc7807.NewProblem().
+			AppendError("Id", err.Error()).
+			SetType("business").
+			SetStatus(http.StatusBadRequest)
+		logger.FromContext(r.Context()).Info(err.Error())
+		err = problem.Write(w)
+		if err != nil {
+			return
+		}
+		return
+	}
 
-	keys, ok := r.URL.Query()["currency"]
-	if ok && len(keys[0]) > 1 {
-		currency = keys[0]
-		value, err = exchangerate.ExchangeCurrency(value, currency)
+	if len(currencies) > 0 && user.Amount != nil {
+		user.Conversions, err = exchangerate.ExchangeCurrencies(ctx, *user.Amount, currencies)
 		if err != nil {
-			errStr := "Url Param 'currency' is not allowable! Have to use existing currency parameter values!"
+			errStr := "Url Param 'currency' is not allowable! Have no exchange rate for that currency!"
 			problem := rfc7807.NewProblem().
-				AppendError("Id", errStr).
+				AppendError("currency", errStr).
 				SetType("business").
 				SetStatus(http.StatusBadRequest)
-			env.logger.Info(err.Error(), whereami.WhereAmI())
+			logger.FromContext(r.Context()).Info(err.Error())
 			err = problem.Write(w)
 			if err != nil {
 				return
@@ -96,8 +119,7 @@ func (env *Environment) GetBalance(w http.ResponseWriter, r *http.Request) {
 		}
 	}
 
-	result := fmt.Sprintf("%s %s", value, currency)
-	body, err := json.Marshal(result)
+	body, err := json.Marshal(user)
 	if err != nil {
 		log.Println(err)
 		return
@@ -122,7 +144,7 @@ func (env *Environment) GetHistory(w http.ResponseWriter, r *http.Request) {
 			SetType("business").
 			SetStatus(http.StatusBadRequest)
 		err = problem.Write(w)
-		env.logger.Info(errStr, whereami.WhereAmI())
+		logger.FromContext(r.Context()).Info(errStr)
 		if err != nil {
 			return
 		}
@@ -133,27 +155,57 @@ func (env *Environment) GetHistory(w http.ResponseWriter, r *http.Request) {
 			AppendError("Id", err.Error()).
 			SetType("business").
 			SetStatus(http.StatusBadRequest)
-		env.logger.Info(err.Error(), whereami.WhereAmI())
+		logger.FromContext(r.Context()).Info(err.Error())
 		err = problem.Write(w)
 		if err != nil {
 			return
 		}
 		return
 	}
+	logger.Annotate(ctx, "BalanceID", id)
+	if !owns(w, r, "Id", id) {
+		return
+	}
 
 	keys := r.URL.Query()
+	currencies := validator.SplitCurrencies(keys.Get("currency"))
+	if err := validator.ValidateCurrencies(ctx, currencies); err != nil {
+		problem := rfc7807.NewProblem().
+			AppendError("currency", err.Error()).
+			SetType("business").
+			SetStatus(http.StatusBadRequest)
+		logger.FromContext(r.Context()).Info(err.Error())
+		err = problem.Write(w)
+		if err != nil {
+			return
+		}
+		return
+	}
 	order_by := validator.ValidateQueryKey(keys.Get("order_by"), "id")
 	limit := validator.ValidateQueryKey(keys.Get("limit"), "null")
 	offset := validator.ValidateQueryKey(keys.Get("offset"), "null")
 
 	transactions, err := repository.GetHistoryPg(ctx, env.Balances, int64(id), order_by, limit, offset)
+	if err != nil {
+		problem := rfc7807.NewProblem().
+			AppendError("Server", "Internal server error!").
+			SetType("server").
+			SetStatus(http.StatusInternalServerError)
+		logger.FromContext(r.Context()).Error(err.Error())
+		err = problem.Write(w)
+		if err != nil {
+			return
+		}
+		return
+	}
+
 	if len(transactions) < 1 {
 		err = errors.New("Have no user or transactions with that id!")
 		problem := rfc7807.NewProblem().
 			AppendError("Id", err.Error()).
 			SetType("business").
 			SetStatus(http.StatusBadRequest)
-		env.logger.Info(err.Error(), whereami.WhereAmI())
+		logger.FromContext(r.Context()).Info(err.Error())
 		err = problem.Write(w)
 		if err != nil {
 			return
@@ -161,10 +213,27 @@ func (env *Environment) GetHistory(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	if err != nil {
-		log.Println(err)
-		env.logger.Error(err.Error(), whereami.WhereAmI())
-		return
+	if len(currencies) > 0 {
+		for i := range transactions {
+			// Rows written without an amount have nothing to convert.
+			if transactions[i].Amount == nil {
+				continue
+			}
+			transactions[i].Conversions, err = exchangerate.ExchangeCurrencies(ctx, *transactions[i].Amount, currencies)
+			if err != nil {
+				errStr := "Url Param 'currency' is not allowable! Have no exchange rate for that currency!"
+				problem := rfc7807.NewProblem().
+					AppendError("currency", errStr).
+					SetType("business").
+					SetStatus(http.StatusBadRequest)
+				logger.FromContext(r.Context()).Info(err.Error())
+				err = problem.Write(w)
+				if err != nil {
+					return
+				}
+				return
+			}
+		}
 	}
 
 	body, err := json.Marshal(transactions)
@@ -182,20 +251,21 @@ func (env *Environment) GetHistory(w http.ResponseWriter, r *http.Request) {
 
 func (env *Environment) TransferTransaction(w http.ResponseWriter, r *http.Request) {
 	ctx := r.Context()
+	defer env.beginOperation()()
 	transaction := jsonint.TransactionJSON{}
-	err := jsonint.BodyToJSON(r.Body, &transaction)
+	err := jsonint.DecodeJSONBody(w, r, env.MaxBodyBytes, &transaction)
 	if err != nil {
-		errStr := "Id must be positive integer! Reason and amount must be decimal in string!"
-		problem := rfc7807.NewProblem().
-			AppendError("Id", errStr).
-			SetType("business").
-			SetStatus(http.StatusBadRequest)
+		problem := jsonint.DecodeProblem(err)
+		logger.FromContext(r.Context()).Info(err.Error())
 		err = problem.Write(w)
 		if err != nil {
 			return
 		}
 		return
 	}
+	if client, ok := auth.FromContext(ctx); ok {
+		transaction.ClientId = jsonint.Some(client.ID)
+	}
 
 	if !transaction.FromId.Valid {
 		errStr := "Id must be positive integer, not null!"
@@ -223,8 +293,55 @@ func (env *Environment) TransferTransaction(w http.ResponseWriter, r *http.Reque
 		return
 	}
 
+	var limited money.Money
+	if transaction.QuoteId.Valid {
+		quote, err := repository.GetQuotePg(ctx, env.Balances, transaction.QuoteId.V)
+		if err != nil {
+			problem := rfc7807.NewProblem().
+				AppendError("Server", "Internal server error!").
+				SetType("server").
+				SetStatus(http.StatusInternalServerError)
+			logger.FromContext(r.Context()).Error(err.Error())
+			err = problem.Write(w)
+			if err != nil {
+				return
+			}
+			return
+		}
+
+		base, problem := quoteProblem(quote, time.Now())
+		if problem != nil {
+			logger.FromContext(r.Context()).Info(problem.Errors[0].Reason)
+			err = problem.Write(w)
+			if err != nil {
+				return
+			}
+			return
+		}
+
+		// The sender pays the source of the quote and the receiver gets its
+		// target, so the transfer runs at the locked rate. The base side is
+		// what the transfer limit applies to.
+		if transaction.Amount.Valid && (transaction.Amount.V.Currency != quote.Source.Currency || !transaction.Amount.V.Amount.Equal(quote.Source.Amount)) {
+			errStr := "Amount must match the quote " + quote.Source.String() + "!"
+			problem := rfc7807.NewProblem().
+				AppendError("amount", errStr).
+				SetType("business").
+				SetStatus(http.StatusBadRequest)
+			logger.FromContext(r.Context()).Info(errStr)
+			err = problem.Write(w)
+			if err != nil {
+				return
+			}
+			return
+		}
+		transaction.Amount = jsonint.Some(quote.Source)
+		transaction.Credit = jsonint.Some(quote.Target)
+		limited = base
+	}
+
 	if !transaction.Amount.Valid {
-		errStr := "Amount must be positive integer in string!"
+		errStr := "Amount must be decimal, not null!"
 		problem := rfc7807.NewProblem().
 			AppendError("Amount", errStr).
 			SetType("business").
@@ -236,6 +353,35 @@ func (env *Environment) TransferTransaction(w http.ResponseWriter, r *http.Reque
 		return
 	}
 
+	if !transaction.QuoteId.Valid {
+		if err := validator.ValidateAmount(ctx, transaction.Amount.V); err != nil {
+			problem := rfc7807.NewProblem().
+				AppendError("Amount", err.Error()).
+				SetType("business").
+				SetStatus(http.StatusBadRequest)
+			logger.FromContext(r.Context()).Info(err.Error())
+			err = problem.Write(w)
+			if err != nil {
+				return
+			}
+			return
+		}
+		limited = transaction.Amount.V
+	}
+
+	if err := validator.ValidateLimit(ctx, limited, env.Limits().Transfer); err != nil {
+		problem := rfc7807.NewProblem().
+			AppendError("Amount", err.Error()).
+			SetType("business").
+			SetStatus(http.StatusBadRequest)
+		logger.FromContext(r.Context()).Info(err.Error())
+		err = problem.Write(w)
+		if err != nil {
+			return
+		}
+		return
+	}
+
 	if !transaction.Reason.Valid {
 		errStr := "Reason must be string!"
 		problem := rfc7807.NewProblem().
@@ -249,12 +395,31 @@ func (env *Environment) TransferTransaction(w http.ResponseWriter, r *http.Reque
 		return
 	}
 
-	if err := validator.ValidateIds(ctx, transaction.FromId.Value, transaction.ToId.Value); err != nil {
+	if err := validator.ValidateIds(ctx, transaction.FromId.V, transaction.ToId.V); err != nil {
 		problem := rfc7807.NewProblem().
 			AppendError("Id", err.Error()).
 			SetType("business").
 			SetStatus(http.StatusBadRequest)
-		env.logger.Info(err.Error(), whereami.WhereAmI())
+		logger.FromContext(r.Context()).Info(err.Error())
+		err = problem.Write(w)
+		if err != nil {
+			return
+		}
+		return
+	}
+	logger.Annotate(ctx, "FromID", transaction.FromId.V)
+	logger.Annotate(ctx, "ToID", transaction.ToId.V)
+	if !owns(w, r, "fromId", transaction.FromId.V) {
+		return
+	}
+
+	user, err := repository.GetBalancePg(ctx, env.Balances, transaction.FromId.V)
+	if err != nil {
+		problem := rfc7807.NewProblem().
+			AppendError("Server", "Internal server error!").
+			SetType("server").
+			SetStatus(http.StatusInternalServerError)
+		logger.FromContext(r.Context()).Error(err.Error())
 		err = problem.Write(w)
 		if err != nil {
 			return
@@ -262,14 +427,13 @@ func (env *Environment) TransferTransaction(w http.ResponseWriter, r *http.Reque
 		return
 	}
 
-	user, err := repository.GetBalancePg(ctx, env.Balances, transaction.FromId.Value)
 	if user.ID == nil {
 		err = errors.New("Have no balance with that id!")
 		problem := rfc7807.NewProblem().
 			AppendError("Id", err.Error()).
 			SetType("business").
 			SetStatus(http.StatusBadRequest)
-		env.logger.Info(err.Error(), whereami.WhereAmI())
+		logger.FromContext(r.Context()).Info(err.Error())
 		err = problem.Write(w)
 		if err != nil {
 			return
@@ -277,18 +441,15 @@ func (env *Environment) TransferTransaction(w http.ResponseWriter, r *http.Reque
 		return
 	}
 
-	if err != nil {
-		log.Println(err)
-		env.logger.Error(err.Error(), whereami.WhereAmI())
-		return
-	}
-
-	if err := validator.ValidateBalanceForTransaction(ctx, user.Amount, transaction.Amount.Value); err != nil {
+	if err := validator.ValidateBalanceForTransaction(ctx, user.Amount, transaction.Amount.V); err != nil {
+		if errors.Is(err, validator.ErrNotEnoughMoney) {
+			metrics.NotEnoughMoney("transfer")
+		}
 		problem := rfc7807.NewProblem().
 			AppendError("Balance", err.Error()).
 			SetType("business").
 			SetStatus(http.StatusBadRequest)
-		env.logger.Info(err.Error(), whereami.WhereAmI())
+		logger.FromContext(r.Context()).Info(err.Error())
 		err = problem.Write(w)
 		if err != nil {
 			return
@@ -296,12 +457,48 @@ func (env *Environment) TransferTransaction(w http.ResponseWriter, r *http.Reque
 		return
 	}
 
+	transaction.TransferId = jsonint.Some(ksuid.New().String())
+	logger.Annotate(ctx, "OperationID", transaction.TransferId.V)
 	err = repository.TransferTransactionPg(ctx, env.Balances, transaction)
+	if err == repository.ErrQuoteUnavailable {
+		problem := rfc7807.NewProblem().
+			AppendError("quoteId", err.Error()).
+			SetType("business").
+			SetStatus(http.StatusConflict)
+		logger.FromContext(r.Context()).Info(err.Error())
+		err = problem.Write(w)
+		if err != nil {
+			return
+		}
+		return
+	}
+
+	if err == repository.ErrBalanceCurrency {
+		problem := rfc7807.NewProblem().
+			AppendError("toId", err.Error()).
+			SetType("business").
+			SetStatus(http.StatusConflict)
+		logger.FromContext(r.Context()).Info(err.Error())
+		err = problem.Write(w)
+		if err != nil {
+			return
+		}
+		return
+	}
+
 	if err != nil {
-		log.Println(err)
-		env.logger.Error(err.Error(), whereami.WhereAmI())
+		problem := rfc7807.NewProblem().
+			AppendError("Server", "Internal server error!").
+			SetType("server").
+			SetStatus(http.StatusInternalServerError)
+		logger.FromContext(r.Context()).Error(err.Error())
+		err = problem.Write(w)
+		if err != nil {
+			return
+		}
 		return
 	}
+	metrics.ObserveOperation("transfer", limited)
 
 	body, err := json.Marshal("Done!")
 	if err != nil {
@@ -318,12 +515,26 @@ func (env *Environment) TransferTransaction(w http.ResponseWriter, r *http.Reque
 
 func (env *Environment) IncomeTransaction(w http.ResponseWriter, r *http.Request) {
 	ctx := r.Context()
+	defer env.beginOperation()()
 	transaction := jsonint.TransactionJSON{}
-	err := jsonint.BodyToJSON(r.Body, &transaction)
+	err := jsonint.DecodeJSONBody(w, r, env.MaxBodyBytes, &transaction)
 	if err != nil {
-		errStr := "Id must be positive integer! Amount must be decimal in string!"
+		problem := jsonint.DecodeProblem(err)
+		logger.FromContext(r.Context()).Info(err.Error())
+		err = problem.Write(w)
+		if err != nil {
+			return
+		}
+		return
+	}
+	if client, ok := auth.FromContext(ctx); ok {
+		transaction.ClientId = jsonint.Some(client.ID)
+	}
+
+	if transaction.QuoteId.Set {
+		errStr := "Quote can be used only for transfers!"
 		problem := rfc7807.NewProblem().
-			AppendError("Id", errStr).
+			AppendError("quoteId", errStr).
 			SetType("business").
 			SetStatus(http.StatusBadRequest)
 		err = problem.Write(w)
@@ -347,7 +558,7 @@ func (env *Environment) IncomeTransaction(w http.ResponseWriter, r *http.Request
 	}
 
 	if !transaction.Amount.Valid {
-		errStr := "Amount must be positive integer!"
+		errStr := "Amount must be decimal, not null!"
 		problem := rfc7807.NewProblem().
 			AppendError("Amount", errStr).
 			SetType("business").
@@ -359,27 +570,75 @@ func (env *Environment) IncomeTransaction(w http.ResponseWriter, r *http.Request
 		return
 	}
 
-	if err := validator.ValidateId(ctx, transaction.ToId.Value); err != nil {
+	if err := validator.ValidateAmount(ctx, transaction.Amount.V); err != nil {
+		problem := rfc7807.NewProblem().
+			AppendError("Amount", err.Error()).
+			SetType("business").
+			SetStatus(http.StatusBadRequest)
+		logger.FromContext(r.Context()).Info(err.Error())
+		err = problem.Write(w)
+		if err != nil {
+			return
+		}
+		return
+	}
+
+	if err := validator.ValidateLimit(ctx, transaction.Amount.V, env.Limits().Income); err != nil {
+		problem := rfc7807.NewProblem().
+			AppendError("Amount", err.Error()).
+			SetType("business").
+			SetStatus(http.StatusBadRequest)
+		logger.FromContext(r.Context()).Info(err.Error())
+		err = problem.Write(w)
+		if err != nil {
+			return
+		}
+		return
+	}
+
+	if err := validator.ValidateId(ctx, transaction.ToId.V); err != nil {
 		problem := rfc7807.NewProblem().
 			AppendError("Id", err.Error()).
 			SetType("business").
 			SetStatus(http.StatusBadRequest)
-		env.logger.Info(err.Error(), whereami.WhereAmI())
+		logger.FromContext(r.Context()).Info(err.Error())
 		err = problem.Write(w)
 		if err != nil {
 			return
 		}
 		return
 	}
-	transaction.Type.Value = "income"
-	transaction.FromId.Value = 0
+	logger.Annotate(ctx, "ToID", transaction.ToId.V)
+	transaction.Type.V = "income"
+	transaction.FromId.V = 0
 
 	err = repository.IncomeTransactionPg(ctx, env.Balances, transaction)
+	if err == repository.ErrBalanceCurrency {
+		problem := rfc7807.NewProblem().
+			AppendError("toId", err.Error()).
+			SetType("business").
+			SetStatus(http.StatusConflict)
+		logger.FromContext(r.Context()).Info(err.Error())
+		err = problem.Write(w)
+		if err != nil {
+			return
+		}
+		return
+	}
+
 	if err != nil {
-		log.Println(err)
-		env.logger.Error(err.Error(), whereami.WhereAmI())
+		problem := rfc7807.NewProblem().
+			AppendError("Server", "Internal server error!").
+			SetType("server").
+			SetStatus(http.StatusInternalServerError)
+		logger.FromContext(r.Context()).Error(err.Error())
+		err = problem.Write(w)
+		if err != nil {
+			return
+		}
 		return
 	}
+	metrics.ObserveOperation("income", transaction.Amount.V)
 
 	body, err := json.Marshal("Done!")
 	if err != nil {
@@ -396,12 +655,26 @@ func (env *Environment) IncomeTransaction(w http.ResponseWriter, r *http.Request
 
 func (env *Environment) OutcomeTransaction(w http.ResponseWriter, r *http.Request) {
 	ctx := r.Context()
+	defer env.beginOperation()()
 	transaction := jsonint.TransactionJSON{}
-	err := jsonint.BodyToJSON(r.Body, &transaction)
+	err := jsonint.DecodeJSONBody(w, r, env.MaxBodyBytes, &transaction)
 	if err != nil {
-		errStr := "Id must be positive integer! Value must be decimal in string "
+		problem := jsonint.DecodeProblem(err)
+		logger.FromContext(r.Context()).Info(err.Error())
+		err = problem.Write(w)
+		if err != nil {
+			return
+		}
+		return
+	}
+	if client, ok := auth.FromContext(ctx); ok {
+		transaction.ClientId = jsonint.Some(client.ID)
+	}
+
+	if transaction.QuoteId.Set {
+		errStr := "Quote can be used only for transfers!"
 		problem := rfc7807.NewProblem().
-			AppendError("Id", errStr).
+			AppendError("quoteId", errStr).
 			SetType("business").
 			SetStatus(http.StatusBadRequest)
 		err = problem.Write(w)
@@ -425,7 +698,7 @@ func (env *Environment) OutcomeTransaction(w http.ResponseWriter, r *http.Reques
 	}
 
 	if !transaction.Amount.Valid {
-		errStr := "Amount must be positive integer!"
+		errStr := "Amount must be decimal, not null!"
 		problem := rfc7807.NewProblem().
 			AppendError("Amount", errStr).
 			SetType("business").
@@ -437,12 +710,12 @@ func (env *Environment) OutcomeTransaction(w http.ResponseWriter, r *http.Reques
 		return
 	}
 
-	if err := validator.ValidateId(ctx, transaction.FromId.Value); err != nil {
+	if err := validator.ValidateAmount(ctx, transaction.Amount.V); err != nil {
 		problem := rfc7807.NewProblem().
-			AppendError("Id", err.Error()).
+			AppendError("Amount", err.Error()).
 			SetType("business").
 			SetStatus(http.StatusBadRequest)
-		env.logger.Info(err.Error(), whereami.WhereAmI())
+		logger.FromContext(r.Context()).Info(err.Error())
 		err = problem.Write(w)
 		if err != nil {
 			return
@@ -450,33 +723,73 @@ func (env *Environment) OutcomeTransaction(w http.ResponseWriter, r *http.Reques
 		return
 	}
 
-	user, err := repository.GetBalancePg(ctx, env.Balances, transaction.FromId.Value)
-	if user.ID == nil {
-		err = errors.New("Have no balance with that id!")
+	if err := validator.ValidateLimit(ctx, transaction.Amount.V, env.Limits().Outcome); err != nil {
+		problem := rfc7807.NewProblem().
+			AppendError("Amount", err.Error()).
+			SetType("business").
+			SetStatus(http.StatusBadRequest)
+		logger.FromContext(r.Context()).Info(err.Error())
+		err = problem.Write(w)
+		if err != nil {
+			return
+		}
+		return
+	}
+
+	if err := validator.ValidateId(ctx, transaction.FromId.V); err != nil {
 		problem := rfc7807.NewProblem().
 			AppendError("Id", err.Error()).
 			SetType("business").
 			SetStatus(http.StatusBadRequest)
-		env.logger.Info(err.Error(), whereami.WhereAmI())
+		logger.FromContext(r.Context()).Info(err.Error())
 		err = problem.Write(w)
 		if err != nil {
 			return
 		}
 		return
 	}
+	logger.Annotate(ctx, "FromID", transaction.FromId.V)
+	if !owns(w, r, "fromId", transaction.FromId.V) {
+		return
+	}
 
+	user, err := repository.GetBalancePg(ctx, env.Balances, transaction.FromId.V)
 	if err != nil {
-		log.Println(err)
-		env.logger.Error(err.Error(), whereami.WhereAmI())
+		problem := rfc7807.NewProblem().
+			AppendError("Server", "Internal server error!").
+			SetType("server").
+			SetStatus(http.StatusInternalServerError)
+		logger.FromContext(r.Context()).Error(err.Error())
+		err = problem.Write(w)
+		if err != nil {
+			return
+		}
 		return
 	}
 
-	if err := validator.ValidateBalanceForTransaction(ctx, user.Amount, transaction.Amount.Value); err != nil {
+	if user.ID == nil {
+		err = errors.New("Have no balance with that id!")
+		problem := rfc7807.NewProblem().
+			AppendError("Id", err.Error()).
+			SetType("business").
+			SetStatus(http.StatusBadRequest)
+		logger.FromContext(r.Context()).Info(err.Error())
+		err = problem.Write(w)
+		if err != nil {
+			return
+		}
+		return
+	}
+
+	if err := validator.ValidateBalanceForTransaction(ctx, user.Amount, transaction.Amount.V); err != nil {
+		if errors.Is(err, validator.ErrNotEnoughMoney) {
+			metrics.NotEnoughMoney("outcome")
+		}
 		problem := rfc7807.NewProblem().
 			AppendError("Balance", err.Error()).
 			SetType("business").
 			SetStatus(http.StatusBadRequest)
-		env.logger.Info(err.Error(), whereami.WhereAmI())
+		logger.FromContext(r.Context()).Info(err.Error())
 		err = problem.Write(w)
 		if err != nil {
 			return
@@ -484,15 +797,16 @@ func (env *Environment) OutcomeTransaction(w http.ResponseWriter, r *http.Reques
 		return
 	}
 
-	transaction.Type.Value = "outcome"
-	transaction.ToId.Value = 0
+	transaction.Type.V = "outcome"
+	transaction.ToId.V = 0
 
 	err = repository.OutcomeTransactionPg(ctx, env.Balances, transaction)
 	if err != nil {
 		log.Println(err)
-		env.logger.Error(err.Error(), whereami.WhereAmI())
+		logger.FromContext(r.Context()).Error(err.Error())
 		return
 	}
+	metrics.ObserveOperation("outcome", transaction.Amount.V)
 
 	body, err := json.Marshal("Done!")
 	if err != nil {
@@ -506,3 +820,22 @@ func (env *Environment) OutcomeTransaction(w http.ResponseWriter, r *http.Reques
 		return
 	}
 }
+
+// owns tells whether the caller may read or debit balance id, answering 403
+// when it may not. Callers acting for no user, such as API clients, may touch
+// any balance.
+func owns(w http.ResponseWriter, r *http.Request, field string, id int64) bool {
+	client, ok := auth.FromContext(r.Context())
+	if !ok || client.Owns(id) {
+		return true
+	}
+	problem := rfc7807.NewProblem().
+		AppendError(field, "Balance doesn't belong to the caller!").
+		SetType("auth").
+		SetStatus(http.StatusForbidden)
+	logger.FromContext(r.Context()).Info("Client " + client.ID + " doesn't own balance " + strconv.FormatInt(id, 10))
+	if err := problem.Write(w); err != nil {
+		return false
+	}
+	return false
+}
@@ -0,0 +1,41 @@
+package controller
+
+import (
+	"encoding/json"
+	"log"
+	"net/http"
+
+	"job/application/exchangerate"
+	"job/domain/currency"
+	"job/domain/models"
+	"job/domain/money"
+	"job/presentation/core/logger"
+)
+
+func (env *Environment) GetCurrencies(w http.ResponseWriter, r *http.Request) {
+	rates, err := exchangerate.Rates(r.Context())
+	if err != nil {
+		logger.FromContext(r.Context()).Warn(err.Error())
+	}
+
+	currencies := make([]models.Currency, 0)
+	for _, iso := range currency.All() {
+		_, ok := rates[iso.Code]
+		currencies = append(currencies, models.Currency{
+			Currency: iso,
+			HasRate:  ok || iso.Code == money.BaseCurrency(),
+		})
+	}
+
+	body, err := json.Marshal(currencies)
+	if err != nil {
+		log.Println(err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if _, err := w.Write(body); err != nil {
+		log.Println(err)
+		return
+	}
+}
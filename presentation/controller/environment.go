@@ -1,21 +1,36 @@
 package controller
 
 import (
+	"context"
 	"database/sql"
+	"errors"
+	"log/slog"
+	"sync"
+	"sync/atomic"
+	"time"
 
+	"job/application/exchangerate"
+	"job/domain/models"
+	"job/domain/money"
 	"job/domain/repository"
+	"job/domain/repository/migrations"
+	"job/presentation/core/auth"
 	"job/presentation/core/config"
 	"job/presentation/core/logger"
+	"job/presentation/core/validator"
 )
 
 type Environment struct {
-	Balances *sql.DB
-	logger   interface {
-		Info(message string, source string)
-		Error(message string, source string)
-		Warning(message string, source string)
-		Fatal(message string, source string)
-	}
+	Balances     *sql.DB
+	MaxBodyBytes int64
+	QuoteTTL     time.Duration
+	operations   sync.WaitGroup
+	limits       atomic.Pointer[validator.Limits]
+	migrator     *migrations.Migrator
+	health       atomic.Pointer[healthSettings]
+	draining     atomic.Bool
+	logger       *slog.Logger
+	closeLog     func() error
 }
 
 func (env *Environment) SetUsersDatabase(db *sql.DB) *Environment {
@@ -23,32 +38,239 @@ func (env *Environment) SetUsersDatabase(db *sql.DB) *Environment {
 	return env
 }
 
-func (env *Environment) SetLogger(logger *logger.Logger) *Environment {
+func (env *Environment) SetMaxBodyBytes(max int64) *Environment {
+	env.MaxBodyBytes = max
+	return env
+}
+
+func (env *Environment) SetQuoteTTL(ttl time.Duration) *Environment {
+	env.QuoteTTL = ttl
+	return env
+}
+
+func (env *Environment) SetLimits(limits *validator.Limits) *Environment {
+	env.limits.Store(limits)
+	return env
+}
+
+// Limits returns the active operation limits.
+func (env *Environment) Limits() validator.Limits {
+	if limits := env.limits.Load(); limits != nil {
+		return *limits
+	}
+	return validator.Limits{}
+}
+
+// SetMigrator lets the readiness probe compare the schema version with the
+// embedded migrations.
+func (env *Environment) SetMigrator(migrator *migrations.Migrator) *Environment {
+	env.migrator = migrator
+	return env
+}
+
+// SetHealth bounds the readiness checks: each probe gets timeout, and the
+// exchange rates may be maxRatesAge old, zero skipping the rates check.
+func (env *Environment) SetHealth(timeout, maxRatesAge time.Duration) *Environment {
+	env.health.Store(&healthSettings{timeout: timeout, maxRatesAge: maxRatesAge})
+	return env
+}
+
+// LookupClient finds the active API client kept in the database whose key
+// hashes to keyHash, nil if there is none.
+func (env *Environment) LookupClient(ctx context.Context, keyHash string) (*auth.Client, error) {
+	client, err := repository.GetClientPg(ctx, env.Balances, keyHash)
+	if err != nil || client == nil {
+		return nil, err
+	}
+	return &auth.Client{ID: client.ID, Scopes: client.Scopes}, nil
+}
+
+// Drain makes the readiness probe fail from now on, so the service is taken
+// out of load balancing while it shuts down.
+func (env *Environment) Drain() {
+	env.draining.Store(true)
+}
+
+func (env *Environment) SetLogger(logger *slog.Logger) *Environment {
 	env.logger = logger
 	return env
 }
 
-func SetMaxConnections(db *sql.DB, max int) {
-	db.SetMaxOpenConns(max)
+// Logger returns the logger request-scoped loggers are made from.
+func (env *Environment) Logger() *slog.Logger {
+	return env.logger
+}
+
+// beginOperation marks a money operation as in flight until the returned
+// func is called, so that Close doesn't pull the database from under it.
+func (env *Environment) beginOperation() func() {
+	env.operations.Add(1)
+	return env.operations.Done
+}
+
+// Close waits for in-flight money operations until ctx is done and closes the
+// database pool and the log output. Call it once the HTTP server has stopped
+// accepting requests. Operations still running at the deadline lose the pool,
+// their transactions are rolled back, and Close reports them.
+func (env *Environment) Close(ctx context.Context) error {
+	done := make(chan struct{})
+	go func() {
+		env.operations.Wait()
+		close(done)
+	}()
+
+	var err error
+	select {
+	case <-done:
+	case <-ctx.Done():
+		err = errors.New("Money operations were still running at the shutdown deadline!")
+	}
+	err = errors.Join(err, env.Balances.Close())
+	if env.closeLog != nil {
+		err = errors.Join(err, env.closeLog())
+	}
+	return err
+}
+
+// Configure applies the process-wide settings: the ledger base currency and
+// how exchange rates are fetched and applied.
+func Configure(conf *models.Config) error {
+	if conf.Application.BaseCurrency != "" {
+		if err := money.SetBaseCurrency(conf.Application.BaseCurrency); err != nil {
+			return err
+		}
+	}
+	if conf.Exchange.ProviderBase != "" {
+		if err := exchangerate.SetProviderBase(conf.Exchange.ProviderBase); err != nil {
+			return err
+		}
+	}
+	return configureExchange(conf)
+}
+
+func configureExchange(conf *models.Config) error {
+	policy, err := exchangerate.NewPolicy(conf.Exchange.BuySpread, conf.Exchange.SellSpread, conf.Exchange.DefaultRounding, conf.Exchange.Rounding)
+	if err != nil {
+		return err
+	}
+	exchangerate.SetPolicy(policy)
+	exchangerate.SetRatesTTL(conf.Exchange.RatesTTL.Duration)
+	return nil
+}
+
+// applyReloadable applies the settings that may change while the service runs.
+func (env *Environment) applyReloadable(conf *models.Config) error {
+	limits, err := validator.NewLimits(conf.Limits.MaxIncome, conf.Limits.MaxOutcome, conf.Limits.MaxTransfer)
+	if err != nil {
+		return err
+	}
+	if err := configureExchange(conf); err != nil {
+		return err
+	}
+	env.SetLimits(limits)
+	env.SetHealth(conf.Health.Timeout.Duration, conf.Health.MaxRatesAge.Duration)
+	return nil
+}
+
+// NewDatabase opens the pool described by conf and waits for the database
+// to answer.
+func NewDatabase(conf *models.Config) (*sql.DB, error) {
+	db, err := repository.NewPgDatabase(repository.PgConfig{
+		User:             conf.Database.User,
+		Password:         conf.Database.Password,
+		Name:             conf.Database.Name,
+		Host:             conf.Database.Host,
+		Port:             conf.Database.Port,
+		SSLMode:          conf.Database.SSLMode,
+		SSLRootCert:      conf.Database.SSLRootCert,
+		SSLCert:          conf.Database.SSLCert,
+		SSLKey:           conf.Database.SSLKey,
+		ApplicationName:  conf.Database.ApplicationName,
+		ConnectTimeout:   conf.Database.ConnectTimeout.Duration,
+		StatementTimeout: conf.Database.StatementTimeout.Duration,
+		MaxOpenConns:     conf.Database.MaxOpenConns,
+		MaxIdleConns:     conf.Database.MaxIdleConns,
+		ConnMaxLifetime:  conf.Database.ConnMaxLifetime.Duration,
+		ConnMaxIdleTime:  conf.Database.ConnMaxIdleTime.Duration,
+	})
+	if err != nil {
+		return nil, err
+	}
+	if err := repository.PingPg(context.Background(), db, conf.Database.PingAttempts, conf.Database.PingBackoff.Duration); err != nil {
+		db.Close()
+		return nil, err
+	}
+	return db, nil
 }
 
 func NewEnvironment() (*Environment, error) {
 	env := new(Environment)
 	conf := config.Get()
-	users, err := repository.NewPgDatabase(conf.Database.User, conf.Database.Password, conf.Database.Host, conf.Database.Name, conf.Database.Port)
+	if err := Configure(conf); err != nil {
+		return nil, err
+	}
+
+	users, err := NewDatabase(conf)
+	if err != nil {
+		return nil, err
+	}
+	migrator, err := migrations.New(users, money.BaseCurrency())
 	if err != nil {
+		users.Close()
 		return nil, err
 	}
+	if conf.Database.AutoMigrate {
+		if _, err := migrator.Up(context.Background()); err != nil {
+			users.Close()
+			return nil, err
+		}
+	}
+
 	logger, err := logger.NewLogger().
 		SetApp(conf.Application.Name).
 		SetVersion(conf.Application.Version).
+		SetLevel(conf.Logger.Level).
+		SetFormat(conf.Logger.Format).
+		SetOutput(conf.Logger.Output).
+		SetFile(logger.File{
+			Path:       conf.Logger.File,
+			MaxSizeMB:  conf.Logger.MaxSizeMB,
+			MaxBackups: conf.Logger.MaxBackups,
+			MaxAgeDays: conf.Logger.MaxAgeDays,
+		}).
+		SetSyslog(logger.Syslog{
+			Network: conf.Logger.SyslogNetwork,
+			Address: conf.Logger.SyslogAddress,
+			Tag:     conf.Logger.SyslogTag,
+		}).
 		CreateLogger()
 	if err != nil {
+		users.Close()
 		return nil, err
 	}
+	env.closeLog = logger.Close
+
+	if err := env.applyReloadable(conf); err != nil {
+		logger.Close()
+		users.Close()
+		return nil, err
+	}
+	config.OnReload(func(conf *models.Config) error {
+		if err := env.applyReloadable(conf); err != nil {
+			return err
+		}
+		logger.SetLevel(conf.Logger.Level)
+		return nil
+	})
+
+	exchangerate.SetOverrideLoader(func(ctx context.Context, now time.Time) ([]models.RateOverride, error) {
+		return repository.ListRateOverridesPg(ctx, users, now)
+	})
 
-	SetMaxConnections(users, 10)
-	env.SetLogger(logger)
+	env.SetLogger(logger.Slog())
 	env.SetUsersDatabase(users)
+	env.SetMigrator(migrator)
+	env.SetMaxBodyBytes(conf.Server.MaxBodyBytes)
+	env.SetQuoteTTL(conf.Exchange.QuoteTTL.Duration)
 	return env, nil
 }
@@ -0,0 +1,129 @@
+package controller
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"runtime"
+	"time"
+
+	"job/application/exchangerate"
+	"job/domain/models"
+	"job/presentation/core/build"
+	"job/presentation/core/config"
+	"job/presentation/core/logger"
+)
+
+type healthSettings struct {
+	timeout     time.Duration
+	maxRatesAge time.Duration
+}
+
+// GetHealth answers the liveness probe: a process that can serve it is alive.
+func (env *Environment) GetHealth(w http.ResponseWriter, r *http.Request) {
+	writeHealth(w, http.StatusOK, models.Health{Status: "ok"})
+}
+
+// GetReadiness answers the readiness probe. The service is ready while it is
+// not shutting down, the database answers, its schema is at the latest
+// migration and the exchange rates are fresh enough. Each check is reported,
+// and any failed one makes the answer 503.
+func (env *Environment) GetReadiness(w http.ResponseWriter, r *http.Request) {
+	settings := healthSettings{}
+	if loaded := env.health.Load(); loaded != nil {
+		settings = *loaded
+	}
+	ctx := r.Context()
+	if settings.timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, settings.timeout)
+		defer cancel()
+	}
+
+	health := models.Health{Status: "ok", Checks: make(map[string]models.HealthCheck)}
+	report := func(name, detail string, err error) {
+		check := models.HealthCheck{Status: "ok", Detail: detail}
+		if err != nil {
+			check.Status = "fail"
+			check.Error = err.Error()
+			health.Status = "fail"
+		}
+		health.Checks[name] = check
+	}
+
+	if env.draining.Load() {
+		report("shutdown", "", errors.New("Service is shutting down!"))
+	}
+
+	report("database", "", env.Balances.PingContext(ctx))
+
+	if env.migrator != nil {
+		current, err := env.migrator.Current(ctx)
+		latest := env.migrator.Latest()
+		// A newer schema is fine: the first pod of a rolling deploy migrates
+		// it while the older ones still serve.
+		if err == nil && current < latest {
+			err = fmt.Errorf("Schema version %d is older than %d!", current, latest)
+		}
+		report("migrations", fmt.Sprintf("Schema version %d of %d", current, latest), err)
+	}
+
+	// The probe only looks at the cached rates, requests download them, so a
+	// provider outage isn't hit by every probe.
+	if settings.maxRatesAge > 0 {
+		if age, ok := exchangerate.RatesAge(); !ok {
+			report("rates", "Not downloaded yet", nil)
+		} else if age > settings.maxRatesAge {
+			report("rates", "Downloaded "+age.Round(time.Second).String()+" ago", fmt.Errorf("Exchange rates are older than %s!", settings.maxRatesAge))
+		} else {
+			report("rates", "Downloaded "+age.Round(time.Second).String()+" ago", nil)
+		}
+	}
+
+	status := http.StatusOK
+	if health.Status != "ok" {
+		logger.FromContext(r.Context()).Warn("Not ready", "Checks", health.Checks)
+		status = http.StatusServiceUnavailable
+	}
+	writeHealth(w, status, health)
+}
+
+// GetVersion tells the VERSION of the running binary, the commit it was
+// built from and when.
+func (env *Environment) GetVersion(w http.ResponseWriter, r *http.Request) {
+	commit, buildTime := build.Info()
+	body, err := json.Marshal(models.BuildInfo{
+		Version:   config.Get().Application.Version,
+		Commit:    commit,
+		BuildTime: buildTime,
+		GoVersion: runtime.Version(),
+	})
+	if err != nil {
+		log.Println(err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if _, err := w.Write(body); err != nil {
+		log.Println(err)
+		return
+	}
+}
+
+func writeHealth(w http.ResponseWriter, status int, health models.Health) {
+	body, err := json.Marshal(health)
+	if err != nil {
+		log.Println(err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	if _, err := w.Write(body); err != nil {
+		log.Println(err)
+		return
+	}
+}
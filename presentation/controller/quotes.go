@@ -0,0 +1,173 @@
+package controller
+
+import (
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"time"
+
+	"job/application/exchangerate"
+	"job/domain/models"
+	"job/domain/money"
+	"job/domain/repository"
+	"job/presentation/core/jsonint"
+	"job/presentation/core/logger"
+	"job/presentation/core/mytime"
+	"job/presentation/core/rfc7807"
+	"job/presentation/core/validator"
+
+	"github.com/segmentio/ksuid"
+)
+
+const defaultQuoteTTL = time.Minute
+
+func (env *Environment) CreateQuote(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	note := jsonint.QuoteJSON{}
+	err := jsonint.DecodeJSONBody(w, r, env.MaxBodyBytes, &note)
+	if err != nil {
+		problem := jsonint.DecodeProblem(err)
+		logger.FromContext(r.Context()).Info(err.Error())
+		err = problem.Write(w)
+		if err != nil {
+			return
+		}
+		return
+	}
+
+	if !note.Amount.Valid {
+		errStr := "Amount must be decimal, not null!"
+		problem := rfc7807.NewProblem().
+			AppendError("amount", errStr).
+			SetType("business").
+			SetStatus(http.StatusBadRequest)
+		err = problem.Write(w)
+		if err != nil {
+			return
+		}
+		return
+	}
+
+	if !note.Currency.Valid {
+		errStr := "Currency must be string, not null!"
+		problem := rfc7807.NewProblem().
+			AppendError("currency", errStr).
+			SetType("business").
+			SetStatus(http.StatusBadRequest)
+		err = problem.Write(w)
+		if err != nil {
+			return
+		}
+		return
+	}
+
+	if err := validator.ValidateQuote(ctx, note.Amount.V, note.Currency.V); err != nil {
+		field := "currency"
+		var fieldErr *validator.FieldError
+		if errors.As(err, &fieldErr) {
+			field = fieldErr.Field
+		}
+		problem := rfc7807.NewProblem().
+			AppendError(field, err.Error()).
+			SetType("business").
+			SetStatus(http.StatusBadRequest)
+		logger.FromContext(r.Context()).Info(err.Error())
+		err = problem.Write(w)
+		if err != nil {
+			return
+		}
+		return
+	}
+
+	conversion, err := exchangerate.ExchangeCurrency(r.Context(), note.Amount.V, note.Currency.V)
+	if err != nil {
+		errStr := "Have no exchange rate for that currency!"
+		problem := rfc7807.NewProblem().
+			AppendError("currency", errStr).
+			SetType("business").
+			SetStatus(http.StatusBadRequest)
+		logger.FromContext(r.Context()).Info(err.Error())
+		err = problem.Write(w)
+		if err != nil {
+			return
+		}
+		return
+	}
+
+	createdAt := time.Now()
+	expiresAt := createdAt.Add(env.quoteTTL())
+	quote := models.Quote{
+		ID:         ksuid.New().String(),
+		Source:     note.Amount.V,
+		Target:     conversion.Amount,
+		MarketRate: conversion.MarketRate,
+		Spread:     conversion.Spread,
+		Rate:       conversion.Rate,
+		Rounding:   conversion.Rounding,
+		Override:   conversion.Override,
+		CreatedAt:  &mytime.MyTime{Time: &createdAt},
+		ExpiresAt:  &mytime.MyTime{Time: &expiresAt},
+	}
+
+	err = repository.AddQuotePg(ctx, env.Balances, quote)
+	if err != nil {
+		problem := rfc7807.NewProblem().
+			AppendError("Server", "Internal server error!").
+			SetType("server").
+			SetStatus(http.StatusInternalServerError)
+		logger.FromContext(r.Context()).Error(err.Error())
+		err = problem.Write(w)
+		if err != nil {
+			return
+		}
+		return
+	}
+
+	body, err := json.Marshal(quote)
+	if err != nil {
+		log.Println(err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	if _, err := w.Write(body); err != nil {
+		log.Println(err)
+		return
+	}
+}
+
+func (env *Environment) quoteTTL() time.Duration {
+	if env.QuoteTTL <= 0 {
+		return defaultQuoteTTL
+	}
+	return env.QuoteTTL
+}
+
+// quoteProblem explains why a transfer can't be made under quote, or returns
+// the base currency side of the quote when it can.
+func quoteProblem(quote *models.Quote, now time.Time) (money.Money, *rfc7807.Problem) {
+	problem := rfc7807.NewProblem().SetType("business")
+	switch {
+	case quote == nil:
+		return money.Money{}, problem.
+			AppendError("quoteId", "Have no quote with that id!").
+			SetStatus(http.StatusBadRequest)
+	case quote.UsedAt != nil:
+		return money.Money{}, problem.
+			AppendError("quoteId", "Quote has already been used!").
+			SetStatus(http.StatusConflict)
+	case !now.Before(*quote.ExpiresAt.Time):
+		return money.Money{}, problem.
+			AppendError("quoteId", "Quote has expired!").
+			SetStatus(http.StatusGone)
+	}
+	base, ok := quote.BaseAmount()
+	if !ok {
+		return money.Money{}, problem.
+			AppendError("quoteId", "Quote must convert from or to "+money.BaseCurrency()+"!").
+			SetStatus(http.StatusBadRequest)
+	}
+	return base, nil
+}
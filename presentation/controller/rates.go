@@ -0,0 +1,221 @@
+package controller
+
+import (
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	"job/application/exchangerate"
+	"job/domain/models"
+	"job/domain/money"
+	"job/domain/repository"
+	"job/presentation/core/jsonint"
+	"job/presentation/core/logger"
+	"job/presentation/core/mytime"
+	"job/presentation/core/rfc7807"
+	"job/presentation/core/validator"
+
+	"github.com/gorilla/mux"
+)
+
+func (env *Environment) GetRates(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	keys := r.URL.Query()
+	base := strings.ToUpper(validator.ValidateQueryKey(keys.Get("base"), money.BaseCurrency()))
+	asOf := keys.Get("asOf")
+
+	if err := validator.ValidateCurrency(ctx, base); err != nil {
+		problem := rfc7807.NewProblem().
+			AppendError("base", err.Error()).
+			SetType("business").
+			SetStatus(http.StatusBadRequest)
+		logger.FromContext(r.Context()).Info(err.Error())
+		err = problem.Write(w)
+		if err != nil {
+			return
+		}
+		return
+	}
+
+	if err := validator.ValidateDate(ctx, asOf); err != nil {
+		problem := rfc7807.NewProblem().
+			AppendError("asOf", err.Error()).
+			SetType("business").
+			SetStatus(http.StatusBadRequest)
+		logger.FromContext(r.Context()).Info(err.Error())
+		err = problem.Write(w)
+		if err != nil {
+			return
+		}
+		return
+	}
+
+	table, err := exchangerate.GetRates(r.Context(), base, asOf)
+	if err != nil {
+		errStr := "Have no exchange rates for that base and date!"
+		problem := rfc7807.NewProblem().
+			AppendError("base", errStr).
+			SetType("business").
+			SetStatus(http.StatusBadGateway)
+		logger.FromContext(r.Context()).Warn(err.Error())
+		err = problem.Write(w)
+		if err != nil {
+			return
+		}
+		return
+	}
+
+	body, err := json.Marshal(table)
+	if err != nil {
+		log.Println(err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if _, err := w.Write(body); err != nil {
+		log.Println(err)
+		return
+	}
+}
+
+func (env *Environment) GetRateOverrides(w http.ResponseWriter, r *http.Request) {
+	overrides, err := repository.ListRateOverridesPg(r.Context(), env.Balances, time.Now())
+	if err != nil {
+		problem := rfc7807.NewProblem().
+			AppendError("Server", "Internal server error!").
+			SetType("server").
+			SetStatus(http.StatusInternalServerError)
+		logger.FromContext(r.Context()).Error(err.Error())
+		err = problem.Write(w)
+		if err != nil {
+			return
+		}
+		return
+	}
+
+	body, err := json.Marshal(overrides)
+	if err != nil {
+		log.Println(err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if _, err := w.Write(body); err != nil {
+		log.Println(err)
+		return
+	}
+}
+
+func (env *Environment) PutRateOverride(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	note := jsonint.RateOverrideJSON{}
+	err := jsonint.DecodeJSONBody(w, r, env.MaxBodyBytes, &note)
+	if err != nil {
+		problem := jsonint.DecodeProblem(err)
+		logger.FromContext(r.Context()).Info(err.Error())
+		err = problem.Write(w)
+		if err != nil {
+			return
+		}
+		return
+	}
+
+	now := time.Now()
+	problem := rfc7807.NewProblem().
+		SetType("business").
+		SetStatus(http.StatusBadRequest)
+	if err := validator.ValidateCurrency(ctx, note.Base.V); !note.Base.Valid || err != nil {
+		problem.AppendError("base", "Base must be ISO 4217 currency code!")
+	}
+	if err := validator.ValidateCurrency(ctx, note.Currency.V); !note.Currency.Valid || err != nil {
+		problem.AppendError("currency", "Currency must be ISO 4217 currency code!")
+	} else if strings.EqualFold(note.Base.V, note.Currency.V) {
+		problem.AppendError("currency", "Currency must differ from base!")
+	}
+	if !note.Rate.Valid || !note.Rate.V.IsPositive() {
+		problem.AppendError("rate", "Rate must be positive decimal!")
+	}
+	if !note.ExpiresAt.Valid || note.ExpiresAt.V.Time == nil || !note.ExpiresAt.V.After(now) {
+		problem.AppendError("expiresAt", "ExpiresAt must be a time in the future!")
+	}
+	if len(problem.Errors) > 0 {
+		logger.FromContext(r.Context()).Info(problem.Errors[0].Reason)
+		err = problem.Write(w)
+		if err != nil {
+			return
+		}
+		return
+	}
+
+	override := models.RateOverride{
+		Base:      strings.ToUpper(note.Base.V),
+		Currency:  strings.ToUpper(note.Currency.V),
+		Rate:      note.Rate.V,
+		CreatedAt: &mytime.MyTime{Time: &now},
+		ExpiresAt: &note.ExpiresAt.V,
+	}
+	if err := repository.SaveRateOverridePg(ctx, env.Balances, override); err != nil {
+		problem := rfc7807.NewProblem().
+			AppendError("Server", "Internal server error!").
+			SetType("server").
+			SetStatus(http.StatusInternalServerError)
+		logger.FromContext(r.Context()).Error(err.Error())
+		err = problem.Write(w)
+		if err != nil {
+			return
+		}
+		return
+	}
+	exchangerate.SetOverride(override)
+	logger.FromContext(r.Context()).Warn("Rate override set for " + override.Base + "/" + override.Currency + ": " + override.Rate.String())
+
+	body, err := json.Marshal(override)
+	if err != nil {
+		log.Println(err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if _, err := w.Write(body); err != nil {
+		log.Println(err)
+		return
+	}
+}
+
+func (env *Environment) DeleteRateOverride(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	base, currency := strings.ToUpper(vars["base"]), strings.ToUpper(vars["currency"])
+	found, err := repository.DeleteRateOverridePg(r.Context(), env.Balances, base, currency, time.Now())
+	if err != nil {
+		problem := rfc7807.NewProblem().
+			AppendError("Server", "Internal server error!").
+			SetType("server").
+			SetStatus(http.StatusInternalServerError)
+		logger.FromContext(r.Context()).Error(err.Error())
+		err = problem.Write(w)
+		if err != nil {
+			return
+		}
+		return
+	}
+	exchangerate.DeleteOverride(base, currency)
+	if !found {
+		err = errors.New("Have no override for that currency pair!")
+		problem := rfc7807.NewProblem().
+			AppendError("currency", err.Error()).
+			SetType("business").
+			SetStatus(http.StatusNotFound)
+		logger.FromContext(r.Context()).Info(err.Error())
+		err = problem.Write(w)
+		if err != nil {
+			return
+		}
+		return
+	}
+	logger.FromContext(r.Context()).Warn("Rate override removed for " + vars["base"] + "/" + vars["currency"])
+
+	w.WriteHeader(http.StatusNoContent)
+}
@@ -0,0 +1,110 @@
+// Package auth describes API clients: who they are and what their keys let
+// them do. Keys are never kept, only their SHA-256 hashes.
+package auth
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"strings"
+)
+
+const (
+	ScopeBalancesRead  = "balances:read"
+	ScopeHistoryRead   = "history:read"
+	ScopeIncomeWrite   = "income:write"
+	ScopeOutcomeWrite  = "outcome:write"
+	ScopeTransferWrite = "transfer:write"
+	ScopeAdmin         = "admin"
+)
+
+// Scopes are the scopes a client can be given.
+var Scopes = map[string]bool{
+	ScopeBalancesRead:  true,
+	ScopeHistoryRead:   true,
+	ScopeIncomeWrite:   true,
+	ScopeOutcomeWrite:  true,
+	ScopeTransferWrite: true,
+	ScopeAdmin:         true,
+}
+
+// Client is the caller an API key or token belongs to. Balances lists the
+// balances a client acting for a user owns; nil means any balance.
+type Client struct {
+	ID       string
+	Scopes   []string
+	Balances []int64
+}
+
+// Owns tells whether the client may read or debit balance id.
+func (client Client) Owns(id int64) bool {
+	if client.Balances == nil {
+		return true
+	}
+	for _, owned := range client.Balances {
+		if owned == id {
+			return true
+		}
+	}
+	return false
+}
+
+// Can tells whether the client was given scope. The empty scope only asks
+// for a known client.
+func (client Client) Can(scope string) bool {
+	if scope == "" {
+		return true
+	}
+	for _, given := range client.Scopes {
+		if given == scope {
+			return true
+		}
+	}
+	return false
+}
+
+// HashKey is how a key is stored and looked up: hex SHA-256.
+func HashKey(key string) string {
+	sum := sha256.Sum256([]byte(key))
+	return hex.EncodeToString(sum[:])
+}
+
+// NewKey makes a random key of 32 bytes, hex encoded.
+func NewKey() (string, error) {
+	key := make([]byte, 32)
+	if _, err := rand.Read(key); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(key), nil
+}
+
+// ParseScopes splits a comma separated list and rejects unknown scopes.
+func ParseScopes(list string) ([]string, error) {
+	scopes := make([]string, 0)
+	for _, scope := range strings.Split(list, ",") {
+		if scope = strings.TrimSpace(scope); scope == "" {
+			continue
+		}
+		if !Scopes[scope] {
+			return nil, fmt.Errorf("Scope %q is unknown!", scope)
+		}
+		scopes = append(scopes, scope)
+	}
+	return scopes, nil
+}
+
+type contextKey struct{}
+
+// NewContext returns ctx carrying the client that made the request.
+func NewContext(ctx context.Context, client Client) context.Context {
+	return context.WithValue(ctx, contextKey{}, client)
+}
+
+// FromContext returns the client that made the request ctx belongs to, false
+// if it wasn't authenticated.
+func FromContext(ctx context.Context) (Client, bool) {
+	client, ok := ctx.Value(contextKey{}).(Client)
+	return client, ok
+}
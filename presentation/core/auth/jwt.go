@@ -0,0 +1,266 @@
+package auth
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math/big"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// TokenClientPrefix starts the id of a client authenticated by a JWT, so its
+// subject can't pass for an API client.
+const TokenClientPrefix = "jwt:"
+
+// VerifierSettings describe where the signing keys come from and what a
+// token must claim. Keys are read again at most every RefreshInterval when a
+// token names an unknown key.
+type VerifierSettings struct {
+	JWKSFile        string
+	JWKSURL         string
+	Issuer          string
+	Audience        string
+	Leeway          time.Duration
+	RefreshInterval time.Duration
+}
+
+// Verifier checks RS256 and ES256 bearer tokens against a JWKS.
+type Verifier struct {
+	settings VerifierSettings
+	client   *http.Client
+
+	mu       sync.RWMutex
+	keys     map[string]crypto.PublicKey
+	loadedAt time.Time
+	loading  chan struct{}
+}
+
+// tokenClaims are the claims a token is read for. Scope is space separated,
+// Balances lists the balances the subject owns.
+type tokenClaims struct {
+	jwt.RegisteredClaims
+	Scope    string  `json:"scope"`
+	Balances []int64 `json:"balances"`
+}
+
+// NewVerifier loads the keys once, so a wrong JWKS stops the start.
+func NewVerifier(ctx context.Context, settings VerifierSettings) (*Verifier, error) {
+	verifier := &Verifier{settings: settings, client: &http.Client{Timeout: 10 * time.Second}}
+	if err := verifier.refresh(ctx); err != nil {
+		return nil, err
+	}
+	return verifier, nil
+}
+
+// Verify checks token and returns its subject as a client limited to the
+// balances the token lists. The admin scope is never granted to a token.
+func (verifier *Verifier) Verify(ctx context.Context, token string) (*Client, error) {
+	claims := new(tokenClaims)
+	_, err := jwt.ParseWithClaims(token, claims, func(token *jwt.Token) (interface{}, error) {
+		kid, _ := token.Header["kid"].(string)
+		return verifier.key(ctx, kid)
+	},
+		jwt.WithValidMethods([]string{"RS256", "ES256"}),
+		jwt.WithIssuer(verifier.settings.Issuer),
+		jwt.WithAudience(verifier.settings.Audience),
+		jwt.WithExpirationRequired(),
+		jwt.WithLeeway(verifier.settings.Leeway),
+	)
+	if err != nil {
+		return nil, err
+	}
+	if claims.Subject == "" || len(TokenClientPrefix+claims.Subject) > 64 {
+		return nil, errors.New("token subject must be 1 to 60 characters")
+	}
+
+	scopes := make([]string, 0)
+	for _, scope := range strings.Fields(claims.Scope) {
+		if Scopes[scope] && scope != ScopeAdmin {
+			scopes = append(scopes, scope)
+		}
+	}
+	balances := claims.Balances
+	if balances == nil {
+		balances = make([]int64, 0)
+	}
+	return &Client{ID: TokenClientPrefix + claims.Subject, Scopes: scopes, Balances: balances}, nil
+}
+
+// key returns the key named kid, reading the JWKS again if it is unknown and
+// old enough. A token without kid is checked with the only key there is.
+func (verifier *Verifier) key(ctx context.Context, kid string) (crypto.PublicKey, error) {
+	verifier.mu.RLock()
+	key, ok := verifier.lookup(kid)
+	verifier.mu.RUnlock()
+	if ok {
+		return key, nil
+	}
+	return verifier.reload(ctx, kid)
+}
+
+// reload reads the JWKS for a missing kid. Only one read runs at a time, the
+// other callers wait for it; the lock isn't held while reading.
+func (verifier *Verifier) reload(ctx context.Context, kid string) (crypto.PublicKey, error) {
+	verifier.mu.Lock()
+	if key, ok := verifier.lookup(kid); ok {
+		verifier.mu.Unlock()
+		return key, nil
+	}
+	if loading := verifier.loading; loading != nil {
+		verifier.mu.Unlock()
+		select {
+		case <-loading:
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+		verifier.mu.RLock()
+		key, ok := verifier.lookup(kid)
+		verifier.mu.RUnlock()
+		if ok {
+			return key, nil
+		}
+		return nil, fmt.Errorf("unknown key %q", kid)
+	}
+	if time.Since(verifier.loadedAt) < verifier.settings.RefreshInterval {
+		verifier.mu.Unlock()
+		return nil, fmt.Errorf("unknown key %q", kid)
+	}
+	loading := make(chan struct{})
+	verifier.loading = loading
+	verifier.loadedAt = time.Now()
+	verifier.mu.Unlock()
+
+	keys, err := verifier.load(ctx)
+
+	verifier.mu.Lock()
+	if err == nil {
+		verifier.keys = keys
+	}
+	verifier.loading = nil
+	close(loading)
+	key, ok := verifier.lookup(kid)
+	verifier.mu.Unlock()
+
+	if err != nil {
+		return nil, err
+	}
+	if !ok {
+		return nil, fmt.Errorf("unknown key %q", kid)
+	}
+	return key, nil
+}
+
+// lookup must be called with mu held.
+func (verifier *Verifier) lookup(kid string) (crypto.PublicKey, bool) {
+	if kid == "" && len(verifier.keys) == 1 {
+		for _, key := range verifier.keys {
+			return key, true
+		}
+	}
+	key, ok := verifier.keys[kid]
+	return key, ok
+}
+
+func (verifier *Verifier) refresh(ctx context.Context) error {
+	loadedAt := time.Now()
+	keys, err := verifier.load(ctx)
+	if err != nil {
+		return err
+	}
+	verifier.mu.Lock()
+	verifier.keys = keys
+	verifier.loadedAt = loadedAt
+	verifier.mu.Unlock()
+	return nil
+}
+
+func (verifier *Verifier) load(ctx context.Context) (map[string]crypto.PublicKey, error) {
+	data, err := verifier.read(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return ParseJWKS(data)
+}
+
+func (verifier *Verifier) read(ctx context.Context) ([]byte, error) {
+	if verifier.settings.JWKSFile != "" {
+		return os.ReadFile(verifier.settings.JWKSFile)
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, verifier.settings.JWKSURL, nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := verifier.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("JWKS answered %s", resp.Status)
+	}
+	return io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+}
+
+// ParseJWKS reads the RSA and P-256 keys of a JSON Web Key Set by kid. Keys
+// of other types or for other uses than signing are skipped.
+func ParseJWKS(data []byte) (map[string]crypto.PublicKey, error) {
+	var set struct {
+		Keys []struct {
+			Kid string `json:"kid"`
+			Kty string `json:"kty"`
+			Use string `json:"use"`
+			Crv string `json:"crv"`
+			N   string `json:"n"`
+			E   string `json:"e"`
+			X   string `json:"x"`
+			Y   string `json:"y"`
+		} `json:"keys"`
+	}
+	if err := json.Unmarshal(data, &set); err != nil {
+		return nil, fmt.Errorf("JWKS is not valid JSON: %v", err)
+	}
+
+	keys := make(map[string]crypto.PublicKey)
+	for _, jwk := range set.Keys {
+		if jwk.Use != "" && jwk.Use != "sig" {
+			continue
+		}
+		switch {
+		case jwk.Kty == "RSA":
+			n, errN := base64.RawURLEncoding.DecodeString(jwk.N)
+			e, errE := base64.RawURLEncoding.DecodeString(jwk.E)
+			if errN != nil || errE != nil || len(e) == 0 || len(e) > 4 {
+				return nil, fmt.Errorf("JWKS key %q is not a valid RSA key", jwk.Kid)
+			}
+			keys[jwk.Kid] = &rsa.PublicKey{N: new(big.Int).SetBytes(n), E: int(new(big.Int).SetBytes(e).Int64())}
+		case jwk.Kty == "EC" && jwk.Crv == "P-256":
+			x, errX := base64.RawURLEncoding.DecodeString(jwk.X)
+			y, errY := base64.RawURLEncoding.DecodeString(jwk.Y)
+			if errX != nil || errY != nil || len(x) != 32 || len(y) != 32 {
+				return nil, fmt.Errorf("JWKS key %q is not a valid P-256 key", jwk.Kid)
+			}
+			key, err := ecdsa.ParseUncompressedPublicKey(elliptic.P256(), append(append([]byte{4}, x...), y...))
+			if err != nil {
+				return nil, fmt.Errorf("JWKS key %q: %v", jwk.Kid, err)
+			}
+			keys[jwk.Kid] = key
+		}
+	}
+	if len(keys) == 0 {
+		return nil, errors.New("JWKS has no RSA or P-256 signing keys")
+	}
+	return keys, nil
+}
@@ -0,0 +1,170 @@
+package auth
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"log"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+func writeJWKS(t *testing.T, ecKey *ecdsa.PrivateKey, rsaKey *rsa.PrivateKey) string {
+	encode := base64.RawURLEncoding.EncodeToString
+	set := map[string]interface{}{"keys": []map[string]string{
+		{"kid": "ec", "kty": "EC", "crv": "P-256", "use": "sig",
+			"x": encode(ecKey.X.FillBytes(make([]byte, 32))), "y": encode(ecKey.Y.FillBytes(make([]byte, 32)))},
+		{"kid": "rsa", "kty": "RSA", "n": encode(rsaKey.N.Bytes()), "e": encode(big.NewInt(int64(rsaKey.E)).Bytes())},
+	}}
+	data, err := json.Marshal(set)
+	if err != nil {
+		t.Fatal(err)
+	}
+	path := filepath.Join(t.TempDir(), "jwks.json")
+	if err := os.WriteFile(path, data, 0o600); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func sign(t *testing.T, method jwt.SigningMethod, kid string, key interface{}, claims jwt.MapClaims) string {
+	token := jwt.NewWithClaims(method, claims)
+	token.Header["kid"] = kid
+	signed, err := token.SignedString(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return signed
+}
+
+func Test_Verifier_ShouldReturn_SuccessResult(t *testing.T) {
+	ecKey, _ := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	rsaKey, _ := rsa.GenerateKey(rand.Reader, 2048)
+	verifier, err := NewVerifier(context.Background(), VerifierSettings{
+		JWKSFile: writeJWKS(t, ecKey, rsaKey),
+		Issuer:   "https://auth.example.com/",
+		Audience: "balanceapp",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	claims := func(changes jwt.MapClaims) jwt.MapClaims {
+		claims := jwt.MapClaims{
+			"sub": "user-7", "iss": "https://auth.example.com/", "aud": "balanceapp",
+			"exp": time.Now().Add(time.Minute).Unix(), "scope": "balances:read transfer:write admin", "balances": []int64{7},
+		}
+		for key, value := range changes {
+			claims[key] = value
+		}
+		return claims
+	}
+
+	for _, token := range []string{
+		sign(t, jwt.SigningMethodES256, "ec", ecKey, claims(nil)),
+		sign(t, jwt.SigningMethodRS256, "rsa", rsaKey, claims(nil)),
+	} {
+		client, err := verifier.Verify(context.Background(), token)
+		if err != nil {
+			log.Printf("Expected a valid token, but got %v\n", err)
+			t.Fatal(err)
+		}
+		if client.ID != "jwt:user-7" || !client.Owns(7) || client.Owns(8) || !client.Can(ScopeTransferWrite) || client.Can(ScopeAdmin) {
+			log.Printf("Expected user-7 owning balance 7 without admin, but got %+v\n", client)
+			t.Fatal(client)
+		}
+	}
+
+	otherKey, _ := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	for name, token := range map[string]string{
+		"expired":  sign(t, jwt.SigningMethodES256, "ec", ecKey, claims(jwt.MapClaims{"exp": time.Now().Add(-time.Minute).Unix()})),
+		"audience": sign(t, jwt.SigningMethodES256, "ec", ecKey, claims(jwt.MapClaims{"aud": "other"})),
+		"issuer":   sign(t, jwt.SigningMethodES256, "ec", ecKey, claims(jwt.MapClaims{"iss": "https://evil.example.com/"})),
+		"key":      sign(t, jwt.SigningMethodES256, "ec", otherKey, claims(nil)),
+		"hs256":    sign(t, jwt.SigningMethodHS256, "ec", []byte("secret"), claims(nil)),
+	} {
+		if _, err := verifier.Verify(context.Background(), token); err == nil {
+			log.Printf("Expected the %s token to be rejected\n", name)
+			t.Fatal(name)
+		}
+	}
+}
+
+func Test_Verifier_ShouldReturn_SuccessResultSingleRefresh(t *testing.T) {
+	ecKey, _ := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	rsaKey, _ := rsa.GenerateKey(rand.Reader, 2048)
+	first, err := os.ReadFile(writeJWKS(t, ecKey, rsaKey))
+	if err != nil {
+		t.Fatal(err)
+	}
+	newKey, _ := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	rotated, err := os.ReadFile(writeJWKS(t, newKey, rsaKey))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var fetches atomic.Int32
+	jwks := atomic.Pointer[[]byte]{}
+	jwks.Store(&first)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fetches.Add(1)
+		time.Sleep(50 * time.Millisecond)
+		w.Write(*jwks.Load())
+	}))
+	defer server.Close()
+
+	verifier, err := NewVerifier(context.Background(), VerifierSettings{
+		JWKSURL:  server.URL,
+		Issuer:   "https://auth.example.com/",
+		Audience: "balanceapp",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The key is rotated under the same kid; the verifiers waiting on the
+	// refresh must share a single read of the JWKS.
+	jwks.Store(&rotated)
+	verifier.mu.Lock()
+	delete(verifier.keys, "ec")
+	verifier.mu.Unlock()
+	token := sign(t, jwt.SigningMethodES256, "ec", newKey, jwt.MapClaims{
+		"sub": "user-7", "iss": "https://auth.example.com/", "aud": "balanceapp",
+		"exp": time.Now().Add(time.Minute).Unix(),
+	})
+
+	var wg sync.WaitGroup
+	errs := make(chan error, 10)
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if _, err := verifier.Verify(context.Background(), token); err != nil {
+				errs <- err
+			}
+		}()
+	}
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		log.Printf("Expected the token checked with the refreshed key, but got %v\n", err)
+		t.Fatal(err)
+	}
+	if got := fetches.Load(); got != 2 {
+		log.Printf("Expected the JWKS read twice, but got %d\n", got)
+		t.Fatal(got)
+	}
+}
@@ -0,0 +1,37 @@
+// Package build tells which commit the binary was built from and when. The
+// values are stamped in at build time:
+//
+//	go build -ldflags "-X job/presentation/core/build.Commit=$(git rev-parse HEAD) -X job/presentation/core/build.Time=$(date -u +%Y-%m-%dT%H:%M:%SZ)"
+//
+// Without them the VCS stamp Go records for builds inside a checkout is used.
+package build
+
+import "runtime/debug"
+
+var (
+	Commit = ""
+	Time   = ""
+)
+
+// Info returns the commit and build time, "unknown" when neither source has
+// them.
+func Info() (commit, time string) {
+	commit, time = Commit, Time
+	if info, ok := debug.ReadBuildInfo(); ok {
+		for _, setting := range info.Settings {
+			switch {
+			case setting.Key == "vcs.revision" && commit == "":
+				commit = setting.Value
+			case setting.Key == "vcs.time" && time == "":
+				time = setting.Value
+			}
+		}
+	}
+	if commit == "" {
+		commit = "unknown"
+	}
+	if time == "" {
+		time = "unknown"
+	}
+	return commit, time
+}
@@ -1,29 +1,145 @@
 package config
 
 import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
 	"io/ioutil"
 	"job/domain/models"
+	"job/presentation/core/mytime"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"time"
 
 	"github.com/BurntSushi/toml"
 )
 
-var config models.Config
+var (
+	current atomic.Pointer[models.Config]
+	version atomic.Pointer[models.ConfigVersion]
+)
 
-func Read(configFile string) (*models.Config, error) {
+func init() {
+	current.Store(new(models.Config))
+	version.Store(&models.ConfigVersion{Rejected: make([]string, 0)})
+}
 
-	if _, err := toml.DecodeFile(configFile, &config); err != nil {
+// Read loads configFile, then applies BALANCEAPP_* environment overrides
+// (see EnvPrefix) and validates the result.
+func Read(configFile string) (*models.Config, error) {
+	conf, checksum, err := load(configFile)
+	if err != nil {
 		return nil, err
 	}
 
-	version, err := ioutil.ReadFile("VERSION")
+	now := time.Now()
+	current.Store(conf)
+	version.Store(&models.ConfigVersion{
+		Version:  1,
+		Checksum: checksum,
+		LoadedAt: &mytime.MyTime{Time: &now},
+		Rejected: make([]string, 0),
+	})
+	return conf, nil
+}
+
+// Get returns the active config. Keep the returned pointer for the duration
+// of one piece of work: a reload swaps in a new config instead of changing it.
+func Get() *models.Config {
+	return current.Load()
+}
+
+// load reads and validates configFile without making it active. The checksum
+// covers the file and the environment overrides.
+func load(configFile string) (*models.Config, string, error) {
+	data, err := ioutil.ReadFile(configFile)
 	if err != nil {
-		return nil, err
+		return nil, "", err
+	}
+	conf := new(models.Config)
+	if _, err := toml.Decode(string(data), conf); err != nil {
+		return nil, "", err
+	}
+
+	version, err := ioutil.ReadFile(filepath.Join(filepath.Dir(configFile), "VERSION"))
+	if err != nil {
+		return nil, "", err
+	}
+	conf.Application.Version = strings.TrimSpace(string(version))
+
+	environ := os.Environ()
+	envErr := applyEnv(conf, environ)
+	setDefaults(conf)
+	if err := errors.Join(envErr, Validate(conf)); err != nil {
+		return nil, "", err
 	}
 
-	config.Application.Version = string(version)
-	return &config, nil
+	sum := sha256.New()
+	sum.Write(data)
+	for _, pair := range environ {
+		if strings.HasPrefix(pair, EnvPrefix+"_") {
+			sum.Write([]byte(pair))
+		}
+	}
+	return conf, hex.EncodeToString(sum.Sum(nil)), nil
 }
 
-func Get() *models.Config {
-	return &config
+// setDefaults fills the logger, server and pool settings left out of the
+// config file, so the server never runs without timeouts.
+func setDefaults(conf *models.Config) {
+	if conf.Logger.Format == "" {
+		conf.Logger.Format = "text"
+	}
+	if conf.Logger.Output == "" {
+		conf.Logger.Output = "stdout"
+	}
+	if conf.Server.Port == 0 {
+		conf.Server.Port = 8080
+	}
+	if conf.Database.SSLMode == "" {
+		conf.Database.SSLMode = "disable"
+	}
+	if conf.Database.ApplicationName == "" {
+		conf.Database.ApplicationName = conf.Application.Name
+	}
+	if conf.Database.MaxOpenConns == 0 {
+		conf.Database.MaxOpenConns = 10
+	}
+	if conf.Database.PingAttempts == 0 {
+		conf.Database.PingAttempts = 5
+	}
+	if conf.AccessLog.MaxBodyBytes == 0 {
+		conf.AccessLog.MaxBodyBytes = 4096
+	}
+	if conf.Tracing.Exporter == "" {
+		conf.Tracing.Exporter = "none"
+	}
+	if conf.JWT.RefreshInterval.Duration == 0 {
+		conf.JWT.RefreshInterval.Duration = 5 * time.Minute
+	}
+	if conf.Signing.MaxSkew.Duration == 0 {
+		conf.Signing.MaxSkew.Duration = 5 * time.Minute
+	}
+	if conf.Health.Timeout.Duration == 0 {
+		conf.Health.Timeout.Duration = 2 * time.Second
+	}
+	defaults := []struct {
+		value    *models.Duration
+		fallback time.Duration
+	}{
+		{&conf.Server.ReadTimeout, 15 * time.Second},
+		{&conf.Server.ReadHeaderTimeout, 5 * time.Second},
+		{&conf.Server.WriteTimeout, 30 * time.Second},
+		{&conf.Server.IdleTimeout, time.Minute},
+		{&conf.Server.ShutdownTimeout, 30 * time.Second},
+		{&conf.Database.ConnectTimeout, 5 * time.Second},
+		{&conf.Database.PingBackoff, time.Second},
+	}
+	for _, d := range defaults {
+		if d.value.Duration <= 0 {
+			d.value.Duration = d.fallback
+		}
+	}
 }
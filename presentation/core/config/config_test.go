@@ -0,0 +1,208 @@
+package config
+
+import (
+	"errors"
+	"io/ioutil"
+	"log"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"job/domain/models"
+)
+
+func validConfig() models.Config {
+	var conf models.Config
+	conf.Application.Name = "balanceapp"
+	conf.Database.Host = "localhost"
+	conf.Database.User = "user"
+	conf.Database.Name = "balances"
+	conf.Database.Port = 5432
+	setDefaults(&conf)
+	return conf
+}
+
+func Test_applyEnv_ShouldReturn_SuccessResult(t *testing.T) {
+	secret := filepath.Join(t.TempDir(), "password")
+	if err := ioutil.WriteFile(secret, []byte("s3cret\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	conf := validConfig()
+	err := applyEnv(&conf, []string{
+		"BALANCEAPP_DATABASE_HOST=db",
+		"BALANCEAPP_DATABASE_PORT=6432",
+		"BALANCEAPP_DATABASE_PASSWORD_FILE=" + secret,
+		"BALANCEAPP_SERVER_SHUTDOWNTIMEOUT=5s",
+		"BALANCEAPP_EXCHANGE_ROUNDING_JPY=down",
+		"OTHER_DATABASE_HOST=ignored",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if conf.Database.Host != "db" || conf.Database.Port != 6432 || conf.Database.Password != "s3cret" {
+		log.Printf("Expected db:6432 with password s3cret, but got %+v\n", conf.Database)
+		t.Fatal(conf.Database)
+	}
+	if conf.Server.ShutdownTimeout.String() != "5s" {
+		log.Printf("Expected 5s, but got %s\n", conf.Server.ShutdownTimeout)
+		t.Fatal(conf.Server.ShutdownTimeout)
+	}
+	if conf.Exchange.Rounding["JPY"] != "down" {
+		log.Printf("Expected down, but got %q\n", conf.Exchange.Rounding["JPY"])
+		t.Fatal(conf.Exchange.Rounding)
+	}
+}
+
+func Test_applyEnv_ShouldReturn_SuccessResultMapFile(t *testing.T) {
+	secret := filepath.Join(t.TempDir(), "gw")
+	if err := ioutil.WriteFile(secret, []byte("0123456789abcdef0123456789abcdef\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	conf := validConfig()
+	if err := applyEnv(&conf, []string{"BALANCEAPP_SIGNING_SECRETS_GW_FILE=" + secret}); err != nil {
+		t.Fatal(err)
+	}
+	if len(conf.Signing.Secrets) != 1 || conf.Signing.Secrets["GW"] != "0123456789abcdef0123456789abcdef" {
+		log.Printf("Expected the GW secret read from the file, but got %q\n", conf.Signing.Secrets)
+		t.Fatal(conf.Signing.Secrets)
+	}
+
+	conf = validConfig()
+	err := applyEnv(&conf, []string{
+		"BALANCEAPP_SIGNING_SECRETS_GW=inline",
+		"BALANCEAPP_SIGNING_SECRETS_GW_FILE=" + secret,
+	})
+	if err == nil || !strings.Contains(err.Error(), "BALANCEAPP_SIGNING_SECRETS_GW and BALANCEAPP_SIGNING_SECRETS_GW_FILE are both set") {
+		log.Printf("Expected both set error, but got %v\n", err)
+		t.Fatal(err)
+	}
+}
+
+func Test_applyEnv_ShouldReturn_SuccessResultJSON(t *testing.T) {
+	conf := validConfig()
+	err := applyEnv(&conf, []string{
+		`BALANCEAPP_SIGNING_ROUTES={"/balances/income": "required"}`,
+		`BALANCEAPP_AUTH_CLIENTS=[{"ID": "gateway", "KeyHash": "` + strings.Repeat("a", 64) + `", "Scopes": ["income:write"]}]`,
+		"BALANCEAPP_SIGNING_SECRETS_gateway=0123456789abcdef0123456789abcdef",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if conf.Signing.Routes["/balances/income"] != "required" {
+		log.Printf("Expected the route from JSON, but got %v\n", conf.Signing.Routes)
+		t.Fatal(conf.Signing.Routes)
+	}
+	if len(conf.Auth.Clients) != 1 || conf.Auth.Clients[0].ID != "gateway" || conf.Auth.Clients[0].Scopes[0] != "income:write" {
+		log.Printf("Expected the gateway client from JSON, but got %+v\n", conf.Auth.Clients)
+		t.Fatal(conf.Auth.Clients)
+	}
+	if _, ok := conf.Signing.Secrets["gateway"]; !ok {
+		log.Printf("Expected the secret under the lower case id, but got %v\n", conf.Signing.Secrets)
+		t.Fatal(conf.Signing.Secrets)
+	}
+
+	err = applyEnv(&conf, []string{"BALANCEAPP_AUTH_CLIENTS=gateway"})
+	if err == nil || !strings.Contains(err.Error(), "BALANCEAPP_AUTH_CLIENTS") {
+		log.Printf("Expected a JSON error, but got %v\n", err)
+		t.Fatal(err)
+	}
+}
+
+func Test_applyEnv_ShouldReturn_ErrorResult(t *testing.T) {
+	conf := validConfig()
+	err := applyEnv(&conf, []string{
+		"BALANCEAPP_DATABASE_PORT=abc",
+		"BALANCEAPP_DATABASE_PASSWORD=secret",
+		"BALANCEAPP_DATABASE_PASSWORD_FILE=/run/secrets/db_password",
+	})
+	if err == nil {
+		t.Fatal("Expected error")
+	}
+	for _, name := range []string{"BALANCEAPP_DATABASE_PORT", "BALANCEAPP_DATABASE_PASSWORD_FILE"} {
+		if !strings.Contains(err.Error(), name) {
+			log.Printf("Expected %s in %q\n", name, err)
+			t.Fatal(err)
+		}
+	}
+}
+
+func Test_Validate_ShouldReturn_ErrorResult(t *testing.T) {
+	conf := validConfig()
+	if err := Validate(&conf); err != nil {
+		t.Fatal(err)
+	}
+
+	conf.Database.Port = 0
+	conf.Application.BaseCurrency = "XYZ"
+	conf.Exchange.BuySpread = "2"
+	err := Validate(&conf)
+	if err == nil {
+		t.Fatal("Expected error")
+	}
+	if lines := strings.Split(err.Error(), "\n"); len(lines) != 3 {
+		log.Printf("Expected 3 invalid settings, but got %q\n", err)
+		t.Fatal(err)
+	}
+}
+
+func Test_Reload_ShouldReturn_SuccessResult(t *testing.T) {
+	dir := t.TempDir()
+	file := filepath.Join(dir, "config.toml")
+	if err := ioutil.WriteFile(filepath.Join(dir, "VERSION"), []byte("1.0.0\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	write := func(level, host string) {
+		text := "[application]\nName = \"balanceapp\"\n[logger]\nLevel = \"" + level + "\"\n" +
+			"[database]\nHost = \"" + host + "\"\nUser = \"user\"\nName = \"balances\"\nPort = 5432\n"
+		if err := ioutil.WriteFile(file, []byte(text), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	write("info", "db")
+	if _, err := Read(file); err != nil {
+		t.Fatal(err)
+	}
+
+	applied := ""
+	OnReload(func(conf *models.Config) error {
+		applied = conf.Logger.Level
+		return nil
+	})
+	OnReload(func(conf *models.Config) error {
+		if conf.Logger.Level == "warn" {
+			return errors.New("Hook refused the config!")
+		}
+		return nil
+	})
+
+	write("debug", "other")
+	version, err := Reload(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if version.Version != 2 || applied != "debug" || Get().Logger.Level != "debug" {
+		log.Printf("Expected version 2 with level debug, but got %d and %q\n", version.Version, applied)
+		t.Fatal(version)
+	}
+	if Get().Database.Host != "db" || len(version.Rejected) != 1 || version.Rejected[0] != "[database] Host" {
+		log.Printf("Expected [database] Host to be rejected, but got %v\n", version.Rejected)
+		t.Fatal(version.Rejected)
+	}
+
+	write("warn", "db")
+	_, err = Reload(file)
+	if err == nil || err.Error() != "Hook refused the config!" || Get().Logger.Level != "debug" || Version().Version != 2 || applied != "debug" {
+		log.Printf("Expected the config refused by a hook to be rolled back, but got %q\n", applied)
+		t.Fatal(err)
+	}
+
+	write("nonsense", "db")
+	if _, err := Reload(file); err == nil || Get().Logger.Level != "debug" {
+		log.Println("Expected invalid config to be rejected")
+		t.Fatal(err)
+	}
+}
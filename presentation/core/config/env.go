@@ -0,0 +1,149 @@
+package config
+
+import (
+	"encoding"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"reflect"
+	"strconv"
+	"strings"
+
+	"job/domain/models"
+)
+
+// EnvPrefix starts the name of every environment variable that overrides a
+// setting: [database] Password becomes BALANCEAPP_DATABASE_PASSWORD, and
+// BALANCEAPP_DATABASE_PASSWORD_FILE names a file holding the value instead.
+// Map entries are set one key at a time, as in BALANCEAPP_EXCHANGE_ROUNDING_JPY,
+// keeping the case of the key, and may be read from files the same way. A
+// whole map or list is set as JSON: BALANCEAPP_SIGNING_ROUTES takes an object
+// and BALANCEAPP_AUTH_CLIENTS an array, replacing the ones from the file.
+const EnvPrefix = "BALANCEAPP"
+
+// applyEnv overrides conf with the variables in environ, given as KEY=value.
+func applyEnv(conf *models.Config, environ []string) error {
+	vars := make(map[string]string, len(environ))
+	for _, pair := range environ {
+		if key, value, ok := strings.Cut(pair, "="); ok {
+			vars[key] = value
+		}
+	}
+
+	var errs []error
+	sections := reflect.ValueOf(conf).Elem()
+	for i := 0; i < sections.NumField(); i++ {
+		section := sections.Field(i)
+		for j := 0; j < section.NumField(); j++ {
+			field := section.Field(j)
+			name := strings.ToUpper(EnvPrefix + "_" + sections.Type().Field(i).Name + "_" + section.Type().Field(j).Name)
+
+			value, ok, err := lookupEnv(vars, name)
+			if err != nil {
+				errs = append(errs, err)
+			} else if ok {
+				if err := setField(field, value); err != nil {
+					errs = append(errs, fmt.Errorf("%s: %v", name, err))
+				}
+			}
+			if field.Kind() == reflect.Map {
+				errs = append(errs, setMap(field, name+"_", vars)...)
+			}
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// lookupEnv reads name, or the file named by name_FILE with trailing newlines
+// trimmed. Setting both is a mistake worth reporting.
+func lookupEnv(vars map[string]string, name string) (string, bool, error) {
+	value, ok := vars[name]
+	path, fromFile := vars[name+"_FILE"]
+	switch {
+	case ok && fromFile:
+		return "", false, fmt.Errorf("%s and %s_FILE are both set, keep one!", name, name)
+	case fromFile:
+		data, err := ioutil.ReadFile(path)
+		if err != nil {
+			return "", false, fmt.Errorf("%s_FILE: %v", name, err)
+		}
+		return strings.TrimRight(string(data), "\r\n"), true, nil
+	}
+	return value, ok, nil
+}
+
+// setMap sets an entry for every variable under prefix, reading it through
+// lookupEnv so that an entry may come from a file too.
+func setMap(field reflect.Value, prefix string, vars map[string]string) []error {
+	keys := make(map[string]bool)
+	for key := range vars {
+		if strings.HasPrefix(key, prefix) {
+			keys[strings.TrimSuffix(strings.TrimPrefix(key, prefix), "_FILE")] = true
+		}
+	}
+
+	var errs []error
+	for key := range keys {
+		// name_FILE holds the whole map.
+		if key == "" || key == "FILE" {
+			continue
+		}
+		value, ok, err := lookupEnv(vars, prefix+key)
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		if !ok {
+			continue
+		}
+		if field.IsNil() {
+			field.Set(reflect.MakeMap(field.Type()))
+		}
+		item := reflect.New(field.Type().Elem()).Elem()
+		if err := setField(item, value); err != nil {
+			errs = append(errs, fmt.Errorf("%s: %v", prefix+key, err))
+			continue
+		}
+		field.SetMapIndex(reflect.ValueOf(key), item)
+	}
+	return errs
+}
+
+func setField(field reflect.Value, value string) error {
+	if unmarshaler, ok := field.Addr().Interface().(encoding.TextUnmarshaler); ok {
+		return unmarshaler.UnmarshalText([]byte(value))
+	}
+
+	switch field.Kind() {
+	case reflect.String:
+		field.SetString(value)
+	case reflect.Int, reflect.Int32, reflect.Int64:
+		number, err := strconv.ParseInt(value, 10, field.Type().Bits())
+		if err != nil {
+			return fmt.Errorf("%q is not an integer", value)
+		}
+		field.SetInt(number)
+	case reflect.Float64:
+		number, err := strconv.ParseFloat(value, 64)
+		if err != nil {
+			return fmt.Errorf("%q is not a number", value)
+		}
+		field.SetFloat(number)
+	case reflect.Bool:
+		flag, err := strconv.ParseBool(value)
+		if err != nil {
+			return fmt.Errorf("%q is not a boolean", value)
+		}
+		field.SetBool(flag)
+	case reflect.Map, reflect.Slice:
+		parsed := reflect.New(field.Type())
+		if err := json.Unmarshal([]byte(value), parsed.Interface()); err != nil {
+			return fmt.Errorf("%q is not JSON of %s: %v", value, field.Type(), err)
+		}
+		field.Set(parsed.Elem())
+	default:
+		return fmt.Errorf("settings of type %s can't be set from the environment", field.Type())
+	}
+	return nil
+}
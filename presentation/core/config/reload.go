@@ -0,0 +1,150 @@
+package config
+
+import (
+	"context"
+	"os"
+	"reflect"
+	"strings"
+	"sync"
+	"time"
+
+	"job/domain/models"
+	"job/presentation/core/mytime"
+)
+
+// reloadable names the settings a reload applies, as Section.Field. A change
+// to any other setting needs a restart and is rejected on reload.
+var reloadable = map[string]bool{
+	"Logger.Level":                true,
+	"Exchange.RatesTTL":           true,
+	"Exchange.BuySpread":          true,
+	"Exchange.SellSpread":         true,
+	"Exchange.DefaultRounding":    true,
+	"Exchange.Rounding":           true,
+	"Limits.MaxIncome":            true,
+	"Limits.MaxOutcome":           true,
+	"Limits.MaxTransfer":          true,
+	"RateLimit.RequestsPerSecond": true,
+	"RateLimit.Burst":             true,
+	"AccessLog.Enabled":           true,
+	"AccessLog.SampleRate":        true,
+	"AccessLog.Routes":            true,
+	"AccessLog.LogBodies":         true,
+	"AccessLog.MaxBodyBytes":      true,
+	"AccessLog.MaskFields":        true,
+	"Health.MaxRatesAge":          true,
+	"Auth.Enabled":                true,
+	"Auth.Clients":                true,
+	"Signing.Routes":              true,
+	"Signing.Secrets":             true,
+	"Signing.MaxSkew":             true,
+}
+
+var (
+	reloadMu sync.Mutex
+	hooks    []func(*models.Config) error
+)
+
+// OnReload registers hook to apply the reloadable settings of every config
+// that replaces the active one. A hook returning an error rejects the config.
+func OnReload(hook func(*models.Config) error) {
+	reloadMu.Lock()
+	defer reloadMu.Unlock()
+	hooks = append(hooks, hook)
+}
+
+// Version describes the active config.
+func Version() *models.ConfigVersion {
+	return version.Load()
+}
+
+// Reload reads configFile again and makes it the active config. An invalid
+// file is rejected as a whole; changed settings that need a restart keep
+// their active values and are listed in the returned version. The config is
+// published only once every hook applied it; when one fails, the hooks that
+// ran are applied the active config again.
+func Reload(configFile string) (*models.ConfigVersion, error) {
+	reloadMu.Lock()
+	defer reloadMu.Unlock()
+
+	conf, checksum, err := load(configFile)
+	if err != nil {
+		return nil, err
+	}
+	active := version.Load()
+	if checksum == active.Checksum {
+		return active, nil
+	}
+
+	previous := current.Load()
+	rejected := keepRestartSettings(previous, conf)
+	for i, hook := range hooks {
+		if err := hook(conf); err != nil {
+			for _, applied := range hooks[:i] {
+				applied(previous)
+			}
+			return nil, err
+		}
+	}
+
+	now := time.Now()
+	next := &models.ConfigVersion{
+		Version:  active.Version + 1,
+		Checksum: checksum,
+		LoadedAt: &mytime.MyTime{Time: &now},
+		Rejected: rejected,
+	}
+
+	current.Store(conf)
+	version.Store(next)
+	return next, nil
+}
+
+// keepRestartSettings copies into conf the active value of every changed
+// setting that isn't reloadable and names those settings.
+func keepRestartSettings(active, conf *models.Config) []string {
+	rejected := make([]string, 0)
+	activeSections := reflect.ValueOf(active).Elem()
+	sections := reflect.ValueOf(conf).Elem()
+	for i := 0; i < sections.NumField(); i++ {
+		sectionName := sections.Type().Field(i).Name
+		for j := 0; j < sections.Field(i).NumField(); j++ {
+			fieldName := sections.Field(i).Type().Field(j).Name
+			if reloadable[sectionName+"."+fieldName] {
+				continue
+			}
+			was, field := activeSections.Field(i).Field(j), sections.Field(i).Field(j)
+			if !reflect.DeepEqual(was.Interface(), field.Interface()) {
+				rejected = append(rejected, "["+strings.ToLower(sectionName)+"] "+fieldName)
+				field.Set(was)
+			}
+		}
+	}
+	return rejected
+}
+
+// Watch calls changed whenever the modification time or size of configFile
+// changes, checking every interval until ctx is done.
+func Watch(ctx context.Context, configFile string, interval time.Duration, changed func()) {
+	if interval <= 0 {
+		return
+	}
+	last, _ := os.Stat(configFile)
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+		info, err := os.Stat(configFile)
+		if err != nil {
+			continue
+		}
+		if last == nil || !info.ModTime().Equal(last.ModTime()) || info.Size() != last.Size() {
+			last = info
+			changed()
+		}
+	}
+}
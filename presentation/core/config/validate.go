@@ -0,0 +1,155 @@
+package config
+
+import (
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"os"
+	"sort"
+
+	"job/application/exchangerate"
+	"job/domain/currency"
+	"job/domain/models"
+	"job/presentation/core/auth"
+	"job/presentation/core/logger"
+	"job/presentation/core/middleware"
+	"job/presentation/core/tracing"
+	"job/presentation/core/validator"
+
+	"github.com/sirupsen/logrus"
+)
+
+// sslModes are the sslmode values lib/pq understands.
+var sslModes = map[string]bool{"disable": true, "require": true, "verify-ca": true, "verify-full": true}
+
+// Validate checks every setting and reports all the invalid ones at once.
+func Validate(conf *models.Config) error {
+	var errs []error
+	check := func(ok bool, format string, args ...interface{}) {
+		if !ok {
+			errs = append(errs, fmt.Errorf(format, args...))
+		}
+	}
+
+	check(conf.Application.Name != "", "[application] Name must not be empty!")
+	if conf.Application.BaseCurrency != "" {
+		_, err := currency.Get(conf.Application.BaseCurrency)
+		check(err == nil, "[application] BaseCurrency %q is not an ISO 4217 code!", conf.Application.BaseCurrency)
+	}
+	check(conf.Application.WatchInterval.Duration >= 0, "[application] WatchInterval must not be negative!")
+
+	if conf.Logger.Level != "" {
+		_, err := logrus.ParseLevel(conf.Logger.Level)
+		check(err == nil, "[logger] Level %q is not a log level!", conf.Logger.Level)
+	}
+	check(logger.Formats[conf.Logger.Format], "[logger] Format must be text or json, not %q!", conf.Logger.Format)
+	check(logger.Outputs[conf.Logger.Output], "[logger] Output must be stdout, stderr, file or syslog, not %q!", conf.Logger.Output)
+	check(conf.Logger.Output != "file" || conf.Logger.File != "", "[logger] File must be set for file output!")
+	check(conf.Logger.MaxSizeMB >= 0 && conf.Logger.MaxBackups >= 0 && conf.Logger.MaxAgeDays >= 0, "[logger] MaxSizeMB, MaxBackups and MaxAgeDays must not be negative!")
+
+	check(conf.Database.Host != "", "[database] Host must not be empty!")
+	check(conf.Database.User != "", "[database] User must not be empty!")
+	check(conf.Database.Name != "", "[database] Name must not be empty!")
+	check(conf.Database.Port > 0 && conf.Database.Port < 65536, "[database] Port must be from 1 to 65535, not %d!", conf.Database.Port)
+	check(sslModes[conf.Database.SSLMode], "[database] SSLMode must be disable, require, verify-ca or verify-full, not %q!", conf.Database.SSLMode)
+	check((conf.Database.SSLCert == "") == (conf.Database.SSLKey == ""), "[database] SSLCert and SSLKey must be set together!")
+	for _, path := range []string{conf.Database.SSLRootCert, conf.Database.SSLCert, conf.Database.SSLKey} {
+		if path != "" {
+			_, err := os.Stat(path)
+			check(err == nil, "[database] %v", err)
+		}
+	}
+	check(conf.Database.StatementTimeout.Duration >= 0, "[database] StatementTimeout must not be negative!")
+	check(conf.Database.MaxOpenConns >= 0, "[database] MaxOpenConns must not be negative!")
+	check(conf.Database.MaxIdleConns >= 0 && conf.Database.MaxIdleConns <= conf.Database.MaxOpenConns, "[database] MaxIdleConns must be from 0 to MaxOpenConns!")
+	check(conf.Database.ConnMaxLifetime.Duration >= 0, "[database] ConnMaxLifetime must not be negative!")
+	check(conf.Database.ConnMaxIdleTime.Duration >= 0, "[database] ConnMaxIdleTime must not be negative!")
+	check(conf.Database.PingAttempts > 0, "[database] PingAttempts must be positive!")
+
+	check(conf.Server.Port > 0 && conf.Server.Port < 65536, "[server] Port must be from 1 to 65535, not %d!", conf.Server.Port)
+	check(conf.Server.MaxBodyBytes >= 0, "[server] MaxBodyBytes must not be negative!")
+	timeouts := []struct {
+		name  string
+		value models.Duration
+	}{
+		{"ReadTimeout", conf.Server.ReadTimeout},
+		{"ReadHeaderTimeout", conf.Server.ReadHeaderTimeout},
+		{"WriteTimeout", conf.Server.WriteTimeout},
+		{"IdleTimeout", conf.Server.IdleTimeout},
+		{"ShutdownTimeout", conf.Server.ShutdownTimeout},
+	}
+	for _, timeout := range timeouts {
+		check(timeout.value.Duration > 0, "[server] %s must be positive!", timeout.name)
+	}
+
+	if conf.Exchange.ProviderBase != "" {
+		_, err := currency.Get(conf.Exchange.ProviderBase)
+		check(err == nil, "[exchange] ProviderBase %q is not an ISO 4217 code!", conf.Exchange.ProviderBase)
+	}
+	_, err := exchangerate.NewPolicy(conf.Exchange.BuySpread, conf.Exchange.SellSpread, conf.Exchange.DefaultRounding, conf.Exchange.Rounding)
+	check(err == nil, "[exchange] %v", err)
+	check(conf.Exchange.QuoteTTL.Duration >= 0, "[exchange] QuoteTTL must not be negative!")
+	check(conf.Exchange.RatesTTL.Duration >= 0, "[exchange] RatesTTL must not be negative!")
+
+	_, err = validator.NewLimits(conf.Limits.MaxIncome, conf.Limits.MaxOutcome, conf.Limits.MaxTransfer)
+	check(err == nil, "[limits] %v", err)
+
+	check(conf.RateLimit.RequestsPerSecond >= 0, "[ratelimit] RequestsPerSecond must not be negative!")
+	check(conf.RateLimit.RequestsPerSecond == 0 || conf.RateLimit.Burst > 0, "[ratelimit] Burst must be positive when RequestsPerSecond is set!")
+
+	check(conf.AccessLog.SampleRate >= 0 && conf.AccessLog.SampleRate <= 1, "[accesslog] SampleRate must be between 0 and 1!")
+	routes := make([]string, 0, len(conf.AccessLog.Routes))
+	for route := range conf.AccessLog.Routes {
+		routes = append(routes, route)
+	}
+	sort.Strings(routes)
+	for _, route := range routes {
+		rate := conf.AccessLog.Routes[route]
+		check(rate >= 0 && rate <= 1, "[accesslog] Routes %q must be between 0 and 1!", route)
+	}
+	check(conf.AccessLog.MaxBodyBytes >= 0, "[accesslog] MaxBodyBytes must not be negative!")
+
+	check(conf.Tracing.Exporter == "" || tracing.Exporters[conf.Tracing.Exporter], "[tracing] Exporter must be none, stdout or otlp, not %q!", conf.Tracing.Exporter)
+	check(conf.Tracing.Exporter != "otlp" || conf.Tracing.Endpoint != "", "[tracing] Endpoint must be set for the otlp exporter!")
+	check(conf.Tracing.SampleRatio >= 0 && conf.Tracing.SampleRatio <= 1, "[tracing] SampleRatio must be between 0 and 1!")
+
+	ids, hashes := make(map[string]bool), make(map[string]bool)
+	for _, client := range conf.Auth.Clients {
+		check(client.ID != "" && len(client.ID) <= 64, "[auth] Clients ID must be 1 to 64 characters!")
+		check(!ids[client.ID], "[auth] Clients ID %q is repeated!", client.ID)
+		_, err := hex.DecodeString(client.KeyHash)
+		check(err == nil && len(client.KeyHash) == 64, "[auth] Clients KeyHash of %q must be a hex SHA-256!", client.ID)
+		check(!hashes[client.KeyHash], "[auth] Clients KeyHash of %q is repeated!", client.ID)
+		for _, scope := range client.Scopes {
+			check(auth.Scopes[scope], "[auth] Clients scope %q of %q is unknown!", scope, client.ID)
+		}
+		ids[client.ID], hashes[client.KeyHash] = true, true
+	}
+
+	if conf.JWT.Enabled {
+		check((conf.JWT.JWKSFile == "") != (conf.JWT.JWKSURL == ""), "[jwt] Set one of JWKSFile and JWKSURL!")
+		check(conf.JWT.Issuer != "", "[jwt] Issuer must not be empty!")
+		check(conf.JWT.Audience != "", "[jwt] Audience must not be empty!")
+	}
+	check(conf.JWT.Leeway.Duration >= 0, "[jwt] Leeway must not be negative!")
+	check(conf.JWT.RefreshInterval.Duration >= 0, "[jwt] RefreshInterval must not be negative!")
+
+	check(conf.Health.Timeout.Duration >= 0, "[health] Timeout must not be negative!")
+	check(conf.Health.MaxRatesAge.Duration >= 0, "[health] MaxRatesAge must not be negative!")
+
+	signed := make([]string, 0, len(conf.Signing.Routes))
+	for route := range conf.Signing.Routes {
+		signed = append(signed, route)
+	}
+	sort.Strings(signed)
+	for _, route := range signed {
+		mode := conf.Signing.Routes[route]
+		check(mode == middleware.SignatureRequired || mode == middleware.SignatureOptional, "[signing] Routes %q must be required or optional, not %q!", route, mode)
+	}
+	for id, secret := range conf.Signing.Secrets {
+		check(len(secret) >= 32, "[signing] Secrets of %q must be at least 32 characters!", id)
+	}
+	check(conf.Signing.MaxSkew.Duration > 0, "[signing] MaxSkew must be positive!")
+
+	return errors.Join(errs...)
+}
@@ -1,74 +1,193 @@
 package jsonint
 
 import (
+	"bytes"
 	"encoding/json"
+	"errors"
+	"fmt"
 	"io"
-	"io/ioutil"
+	"mime"
+	"net/http"
+	"reflect"
+	"strings"
+
+	"job/domain/money"
+	"job/presentation/core/mytime"
+	"job/presentation/core/rfc7807"
+
+	"github.com/shopspring/decimal"
 )
 
-type JSONInt struct {
-	Value int64
-	Valid bool
-	Set   bool
-}
+const DefaultMaxBodyBytes int64 = 1 << 20
 
-type JSONString struct {
-	Value string
-	Valid bool
-	Set   bool
+type TransactionJSON struct {
+	FromId     Optional[int64]       `json:"fromId"`
+	ToId       Optional[int64]       `json:"toId"`
+	Amount     Optional[money.Money] `json:"amount"`
+	Reason     Optional[string]      `json:"reason"`
+	QuoteId    Optional[string]      `json:"quoteId"`
+	Type       Optional[string]      `json:"-"`
+	TransferId Optional[string]      `json:"-"`
+	ClientId   Optional[string]      `json:"-"`
+	// Credit is what a transfer adds to ToId when it differs from Amount,
+	// as under a quote between two currencies.
+	Credit Optional[money.Money] `json:"-"`
 }
 
-type TransactionJSON struct {
-	FromId JSONInt    `json: "fromId"`
-	ToId   JSONInt    `json: "toId"`
-	Amount JSONString `json: "amount"`
-	Reason JSONString `json: "reason"`
-	Type   JSONString
+type QuoteJSON struct {
+	Amount   Optional[money.Money] `json:"amount"`
+	Currency Optional[string]      `json:"currency"`
 }
 
 type AllRatesJSON struct {
+	Base  string
+	Date  string
 	Rates map[string]float64
 }
 
-func (i *JSONInt) UnmarshalJSON(data []byte) error {
-	i.Set = true
+type RateOverrideJSON struct {
+	Base      Optional[string]          `json:"base"`
+	Currency  Optional[string]          `json:"currency"`
+	Rate      Optional[decimal.Decimal] `json:"rate"`
+	ExpiresAt Optional[mytime.MyTime]   `json:"expiresAt"`
+}
+
+type DecodeError struct {
+	Status int
+	Field  string
+	Reason string
+}
+
+func (e *DecodeError) Error() string {
+	return fmt.Sprintf("%s: %s", e.Field, e.Reason)
+}
+
+// DecodeJSONBody strictly decodes a single JSON document from the request body
+// into note. Bodies larger than maxBytes, unknown fields, trailing data and
+// non-JSON content types are rejected with a *DecodeError.
+func DecodeJSONBody(w http.ResponseWriter, r *http.Request, maxBytes int64, note interface{}) error {
+	mediaType, _, err := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	if err != nil || mediaType != "application/json" {
+		return &DecodeError{
+			Status: http.StatusUnsupportedMediaType,
+			Field:  "Content-Type",
+			Reason: "Content-Type must be application/json!",
+		}
+	}
 
-	if string(data) == "null" {
-		i.Valid = false
-		return nil
+	if maxBytes <= 0 {
+		maxBytes = DefaultMaxBodyBytes
+	}
+	data, err := io.ReadAll(http.MaxBytesReader(w, r.Body, maxBytes))
+	if err != nil {
+		return toDecodeError(err, data, note)
 	}
 
-	var temp int64
-	if err := json.Unmarshal(data, &temp); err != nil {
-		return err
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(note); err != nil {
+		return toDecodeError(err, data, note)
 	}
 
-	i.Value = temp
-	i.Valid = true
+	if err := decoder.Decode(&struct{}{}); err != io.EOF {
+		return &DecodeError{
+			Status: http.StatusBadRequest,
+			Field:  "body",
+			Reason: "Body must contain a single JSON object!",
+		}
+	}
 	return nil
 }
 
-func (s *JSONString) UnmarshalJSON(data []byte) error {
-	s.Set = true
-	if string(data) == "null" {
-		s.Valid = false
-		return nil
-	}
+func toDecodeError(err error, data []byte, note interface{}) *DecodeError {
+	var syntaxError *json.SyntaxError
+	var typeError *json.UnmarshalTypeError
+	var maxBytesError *http.MaxBytesError
 
-	var temp string
-	if err := json.Unmarshal(data, &temp); err != nil {
-		return err
+	switch {
+	case errors.As(err, &maxBytesError):
+		return &DecodeError{
+			Status: http.StatusRequestEntityTooLarge,
+			Field:  "body",
+			Reason: fmt.Sprintf("Body must not be larger than %d bytes!", maxBytesError.Limit),
+		}
+	case errors.As(err, &syntaxError):
+		return &DecodeError{
+			Status: http.StatusBadRequest,
+			Field:  "body",
+			Reason: fmt.Sprintf("Body contains malformed JSON at position %d!", syntaxError.Offset),
+		}
+	case errors.Is(err, io.ErrUnexpectedEOF):
+		return &DecodeError{
+			Status: http.StatusBadRequest,
+			Field:  "body",
+			Reason: "Body contains malformed JSON!",
+		}
+	case errors.Is(err, io.EOF):
+		return &DecodeError{
+			Status: http.StatusBadRequest,
+			Field:  "body",
+			Reason: "Body must not be empty!",
+		}
+	case strings.HasPrefix(err.Error(), "json: unknown field "):
+		field := strings.Trim(strings.TrimPrefix(err.Error(), "json: unknown field "), `"`)
+		return &DecodeError{
+			Status: http.StatusBadRequest,
+			Field:  field,
+			Reason: "Unknown field!",
+		}
+	case errors.As(err, &typeError):
+		field := typeError.Field
+		if field == "" {
+			field = locateField(data, note)
+		}
+		return &DecodeError{
+			Status: http.StatusBadRequest,
+			Field:  field,
+			Reason: fmt.Sprintf("Value must be %s, not %s!", typeError.Type, typeError.Value),
+		}
+	default:
+		return &DecodeError{
+			Status: http.StatusBadRequest,
+			Field:  locateField(data, note),
+			Reason: err.Error(),
+		}
 	}
-	s.Value = temp
-	s.Valid = true
-	return nil
 }
 
-func BodyToJSON(body io.ReadCloser, note interface{}) error {
-	data, err := ioutil.ReadAll(body)
-	if err != nil {
-		return err
+// locateField finds the top-level field of note whose value in data fails to
+// decode. Errors returned by custom unmarshalers carry no field path of their own.
+func locateField(data []byte, note interface{}) string {
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal(data, &fields); err != nil {
+		return "body"
 	}
-	err = json.Unmarshal(data, &note)
-	return err
+
+	value := reflect.Indirect(reflect.ValueOf(note))
+	if value.Kind() != reflect.Struct {
+		return "body"
+	}
+
+	for i := 0; i < value.NumField(); i++ {
+		name := strings.Split(value.Type().Field(i).Tag.Get("json"), ",")[0]
+		raw, ok := fields[name]
+		if name == "" || name == "-" || !ok {
+			continue
+		}
+		field := reflect.New(value.Field(i).Type())
+		if err := json.Unmarshal(raw, field.Interface()); err != nil {
+			return name
+		}
+	}
+	return "body"
+}
+
+// DecodeProblem converts an error returned by DecodeJSONBody into a problem response.
+func DecodeProblem(err error) *rfc7807.Problem {
+	decodeError := &DecodeError{Status: http.StatusBadRequest, Field: "body", Reason: err.Error()}
+	errors.As(err, &decodeError)
+	return rfc7807.NewProblem().
+		AppendError(decodeError.Field, decodeError.Reason).
+		SetType("business").
+		SetStatus(decodeError.Status)
 }
@@ -0,0 +1,79 @@
+package jsonint
+
+import (
+	"bytes"
+	"database/sql"
+	"database/sql/driver"
+	"encoding/json"
+)
+
+// Optional tells apart a value that is absent (Set is false), null (Valid is
+// false) or set. It decodes from JSON and scans from SQL, so the same type is
+// used for request bodies and database rows.
+type Optional[T any] struct {
+	V     T
+	Valid bool
+	Set   bool
+}
+
+func Some[T any](value T) Optional[T] {
+	return Optional[T]{V: value, Valid: true, Set: true}
+}
+
+func (o *Optional[T]) UnmarshalJSON(data []byte) error {
+	o.Set = true
+	if bytes.Equal(data, []byte("null")) {
+		var zero T
+		o.V = zero
+		o.Valid = false
+		return nil
+	}
+
+	if err := json.Unmarshal(data, &o.V); err != nil {
+		return err
+	}
+	o.Valid = true
+	return nil
+}
+
+func (o Optional[T]) MarshalJSON() ([]byte, error) {
+	if !o.Valid {
+		return []byte("null"), nil
+	}
+	return json.Marshal(o.V)
+}
+
+// IsZero reports whether the value was never set, for use with omitzero.
+func (o Optional[T]) IsZero() bool {
+	return !o.Set && !o.Valid
+}
+
+func (o *Optional[T]) Scan(src interface{}) error {
+	var null sql.Null[T]
+	if err := null.Scan(src); err != nil {
+		return err
+	}
+	o.V = null.V
+	o.Valid = null.Valid
+	o.Set = true
+	return nil
+}
+
+func (o Optional[T]) Value() (driver.Value, error) {
+	if !o.Valid {
+		return nil, nil
+	}
+	if valuer, ok := interface{}(o.V).(driver.Valuer); ok {
+		return valuer.Value()
+	}
+	return o.V, nil
+}
+
+// Ptr returns a pointer to the value, or nil when it is absent or null.
+func (o Optional[T]) Ptr() *T {
+	if !o.Valid {
+		return nil
+	}
+	value := o.V
+	return &value
+}
@@ -0,0 +1,45 @@
+package logger
+
+import (
+	"context"
+	"log/slog"
+	"sync"
+)
+
+// scope holds the logger of one request with the fields gathered so far.
+type scope struct {
+	mu     sync.Mutex
+	logger *slog.Logger
+}
+
+type contextKey struct{}
+
+// NewContext returns ctx carrying log for FromContext and Annotate. A nil
+// log stands for slog.Default().
+func NewContext(ctx context.Context, log *slog.Logger) context.Context {
+	if log == nil {
+		log = slog.Default()
+	}
+	return context.WithValue(ctx, contextKey{}, &scope{logger: log})
+}
+
+// FromContext returns the logger of the request ctx belongs to, or
+// slog.Default() outside of a request.
+func FromContext(ctx context.Context) *slog.Logger {
+	if scope, ok := ctx.Value(contextKey{}).(*scope); ok {
+		scope.mu.Lock()
+		defer scope.mu.Unlock()
+		return scope.logger
+	}
+	return slog.Default()
+}
+
+// Annotate adds a field to every later line of the request ctx belongs to,
+// such as the ids of the balances it touches.
+func Annotate(ctx context.Context, key string, value interface{}) {
+	if scope, ok := ctx.Value(contextKey{}).(*scope); ok {
+		scope.mu.Lock()
+		scope.logger = scope.logger.With(key, value)
+		scope.mu.Unlock()
+	}
+}
@@ -1,20 +1,54 @@
 package logger
 
 import (
+	"errors"
+	"fmt"
+	"io"
+	"log/syslog"
 	"os"
 
 	"github.com/segmentio/ksuid"
 	"github.com/sirupsen/logrus"
+	"gopkg.in/natefinch/lumberjack.v2"
+)
+
+// Formats and Outputs are the values the logger config can name.
+var (
+	Formats = map[string]bool{"text": true, "json": true}
+	Outputs = map[string]bool{"stdout": true, "stderr": true, "file": true, "syslog": true}
 )
 
 type Logger struct {
 	logger  *logrus.Logger
+	closer  io.Closer
+	level   string
+	format  string
+	output  string
+	file    File
+	syslog  Syslog
 	host    string
 	guid    string
 	app     string
 	version string
 }
 
+// File is a log file rotated when it grows over MaxSizeMB. MaxBackups and
+// MaxAgeDays bound the rotated files kept, zero keeps them all.
+type File struct {
+	Path       string
+	MaxSizeMB  int
+	MaxBackups int
+	MaxAgeDays int
+}
+
+// Syslog is the syslog daemon lines are sent to. An empty Network and Address
+// mean the local daemon.
+type Syslog struct {
+	Network string
+	Address string
+	Tag     string
+}
+
 func (logger *Logger) Info(message string, source string) {
 	logger.logger.WithFields(logrus.Fields{
 		"GUID":    logger.guid,
@@ -45,14 +79,17 @@ func (logger *Logger) Warning(message string, source string) {
 	}).Warning(message)
 }
 
-func (logger *Logger) Fatal(message string, source string) {
+// Critical logs at error level marked as critical. Unlike logrus' Fatal it
+// leaves the process running, so the caller decides how to stop.
+func (logger *Logger) Critical(message string, source string) {
 	logger.logger.WithFields(logrus.Fields{
-		"GUID":    logger.guid,
-		"Version": logger.version,
-		"App":     logger.app,
-		"Message": message,
-		"Source":  source,
-	}).Fatal(message)
+		"GUID":     logger.guid,
+		"Version":  logger.version,
+		"App":      logger.app,
+		"Message":  message,
+		"Source":   source,
+		"Severity": "critical",
+	}).Error(message)
 }
 
 func (logger *Logger) SetHost(host string) *Logger {
@@ -70,6 +107,47 @@ func (logger *Logger) SetApp(app string) *Logger {
 	return logger
 }
 
+// SetLevel takes effect at once on a created logger. Unknown levels are
+// ignored, config validation rejects them.
+func (logger *Logger) SetLevel(level string) *Logger {
+	logger.level = level
+	if logger.logger != nil {
+		logger.applyLevel()
+	}
+	return logger
+}
+
+// SetFormat picks text or json lines, text by default.
+func (logger *Logger) SetFormat(format string) *Logger {
+	logger.format = format
+	return logger
+}
+
+// SetOutput picks stdout, stderr, file or syslog, stdout by default.
+func (logger *Logger) SetOutput(output string) *Logger {
+	logger.output = output
+	return logger
+}
+
+func (logger *Logger) SetFile(file File) *Logger {
+	logger.file = file
+	return logger
+}
+
+func (logger *Logger) SetSyslog(syslog Syslog) *Logger {
+	logger.syslog = syslog
+	return logger
+}
+
+func (logger *Logger) applyLevel() {
+	if logger.level == "" {
+		return
+	}
+	if level, err := logrus.ParseLevel(logger.level); err == nil {
+		logger.logger.SetLevel(level)
+	}
+}
+
 func NewLogger() *Logger {
 	logger := new(Logger)
 	return logger
@@ -77,8 +155,57 @@ func NewLogger() *Logger {
 
 func (logger *Logger) CreateLogger() (*Logger, error) {
 	logg := logrus.New()
-	logg.SetOutput(os.Stdout)
+
+	switch logger.format {
+	case "", "text":
+	case "json":
+		logg.SetFormatter(&logrus.JSONFormatter{})
+	default:
+		return nil, fmt.Errorf("Log format must be text or json, not %q!", logger.format)
+	}
+
+	switch logger.output {
+	case "", "stdout":
+		logg.SetOutput(os.Stdout)
+	case "stderr":
+		logg.SetOutput(os.Stderr)
+	case "file":
+		if logger.file.Path == "" {
+			return nil, errors.New("Log file path must be set for file output!")
+		}
+		file := &lumberjack.Logger{
+			Filename:   logger.file.Path,
+			MaxSize:    logger.file.MaxSizeMB,
+			MaxBackups: logger.file.MaxBackups,
+			MaxAge:     logger.file.MaxAgeDays,
+		}
+		logg.SetOutput(file)
+		logger.closer = file
+	case "syslog":
+		tag := logger.syslog.Tag
+		if tag == "" {
+			tag = logger.app
+		}
+		writer, err := syslog.Dial(logger.syslog.Network, logger.syslog.Address, syslog.LOG_INFO|syslog.LOG_DAEMON, tag)
+		if err != nil {
+			return nil, err
+		}
+		logg.SetOutput(writer)
+		logger.closer = writer
+	default:
+		return nil, fmt.Errorf("Log output must be stdout, stderr, file or syslog, not %q!", logger.output)
+	}
+
 	logger.guid = ksuid.New().String()
 	logger.logger = logg
+	logger.applyLevel()
 	return logger, nil
 }
+
+// Close releases the log file or syslog connection, if any.
+func (logger *Logger) Close() error {
+	if logger.closer == nil {
+		return nil
+	}
+	return logger.closer.Close()
+}
@@ -0,0 +1,112 @@
+package logger
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"runtime"
+
+	"github.com/sirupsen/logrus"
+)
+
+// LevelCritical is above slog.LevelError. Lines at it are logged as errors
+// marked critical, the process keeps running.
+const LevelCritical = slog.Level(12)
+
+// Slog returns a log/slog logger writing through logger, with its level,
+// format and output, so callers can depend on the standard interface. The
+// calling line is logged as Source.
+func (logger *Logger) Slog() *slog.Logger {
+	return slog.New(&handler{logger: logger})
+}
+
+// handler is a slog.Handler over the logrus logger of a Logger.
+type handler struct {
+	logger *Logger
+	fields logrus.Fields
+	group  string
+}
+
+func (h *handler) Enabled(_ context.Context, level slog.Level) bool {
+	return h.logger.logger.IsLevelEnabled(logrusLevel(level))
+}
+
+func (h *handler) Handle(_ context.Context, record slog.Record) error {
+	fields := logrus.Fields{
+		"GUID":    h.logger.guid,
+		"Version": h.logger.version,
+		"App":     h.logger.app,
+		"Message": record.Message,
+	}
+	if record.PC != 0 {
+		frame, _ := runtime.CallersFrames([]uintptr{record.PC}).Next()
+		fields["Source"] = fmt.Sprintf("Function: %s File: %s Line: %d", frame.Function, frame.File, frame.Line)
+	}
+	for key, value := range h.fields {
+		fields[key] = value
+	}
+	record.Attrs(func(attr slog.Attr) bool {
+		addAttr(fields, h.group, attr)
+		return true
+	})
+	if record.Level >= LevelCritical {
+		fields["Severity"] = "critical"
+	}
+
+	entry := h.logger.logger.WithFields(fields)
+	if !record.Time.IsZero() {
+		entry = entry.WithTime(record.Time)
+	}
+	entry.Log(logrusLevel(record.Level), record.Message)
+	return nil
+}
+
+func (h *handler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	fields := make(logrus.Fields, len(h.fields)+len(attrs))
+	for key, value := range h.fields {
+		fields[key] = value
+	}
+	for _, attr := range attrs {
+		addAttr(fields, h.group, attr)
+	}
+	return &handler{logger: h.logger, fields: fields, group: h.group}
+}
+
+func (h *handler) WithGroup(name string) slog.Handler {
+	if name == "" {
+		return h
+	}
+	return &handler{logger: h.logger, fields: h.fields, group: h.group + name + "."}
+}
+
+// addAttr flattens attr into fields, naming group members group.key.
+func addAttr(fields logrus.Fields, group string, attr slog.Attr) {
+	value := attr.Value.Resolve()
+	if value.Kind() == slog.KindGroup {
+		prefix := group
+		if attr.Key != "" {
+			prefix += attr.Key + "."
+		}
+		for _, member := range value.Group() {
+			addAttr(fields, prefix, member)
+		}
+		return
+	}
+	if attr.Key == "" {
+		return
+	}
+	fields[group+attr.Key] = value.Any()
+}
+
+func logrusLevel(level slog.Level) logrus.Level {
+	switch {
+	case level >= slog.LevelError:
+		return logrus.ErrorLevel
+	case level >= slog.LevelWarn:
+		return logrus.WarnLevel
+	case level >= slog.LevelInfo:
+		return logrus.InfoLevel
+	default:
+		return logrus.DebugLevel
+	}
+}
@@ -0,0 +1,55 @@
+package logger
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"log"
+	"strings"
+	"testing"
+)
+
+func Test_Slog_ShouldReturn_SuccessResultJSON(t *testing.T) {
+	base, err := NewLogger().SetApp("job").SetFormat("json").SetLevel("info").CreateLogger()
+	if err != nil {
+		log.Printf("Expected logger, but got %v\n", err)
+		t.Fatal(err)
+	}
+	var out bytes.Buffer
+	base.logger.SetOutput(&out)
+
+	logg := base.Slog().With("RequestID", "abc")
+	logg.Debug("hidden")
+	logg.WithGroup("balance").Log(context.Background(), LevelCritical, "Not enough money!", "id", 1)
+
+	lines := strings.Split(strings.TrimSpace(out.String()), "\n")
+	if len(lines) != 1 {
+		log.Printf("Expected 1 line, but got %q\n", lines)
+		t.Fatal(lines)
+	}
+	var line map[string]interface{}
+	if err := json.Unmarshal([]byte(lines[0]), &line); err != nil {
+		log.Printf("Expected JSON, but got %s\n", lines[0])
+		t.Fatal(err)
+	}
+	if line["level"] != "error" || line["Severity"] != "critical" || line["App"] != "job" ||
+		line["RequestID"] != "abc" || line["balance.id"] != float64(1) || line["msg"] != "Not enough money!" {
+		log.Printf("Expected critical error fields, but got %v\n", line)
+		t.Fatal(line)
+	}
+	if source, _ := line["Source"].(string); !strings.Contains(source, "slog_test.go") {
+		log.Printf("Expected the calling line as Source, but got %v\n", line["Source"])
+		t.Fatal(line["Source"])
+	}
+}
+
+func Test_CreateLogger_ShouldReturn_ErrorResult(t *testing.T) {
+	if _, err := NewLogger().SetOutput("file").CreateLogger(); err == nil {
+		log.Printf("Expected error for file output without path\n")
+		t.Fatal(err)
+	}
+	if _, err := NewLogger().SetFormat("xml").CreateLogger(); err == nil {
+		log.Printf("Expected error for unknown format\n")
+		t.Fatal(err)
+	}
+}
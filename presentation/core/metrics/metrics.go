@@ -0,0 +1,143 @@
+package metrics
+
+import (
+	"database/sql"
+	"errors"
+	"math"
+	"net/http"
+	"strconv"
+	"time"
+
+	"job/application/exchangerate"
+	"job/domain/money"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+const namespace = "balanceapp"
+
+// Registry holds every metric of the service, served by Handler.
+var Registry = prometheus.NewRegistry()
+
+var (
+	requests = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Subsystem: "http",
+		Name:      "requests_total",
+		Help:      "HTTP requests by route, method and status.",
+	}, []string{"route", "method", "status"})
+
+	latency = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Subsystem: "http",
+		Name:      "request_duration_seconds",
+		Help:      "HTTP request latency by route, method and status.",
+		Buckets:   prometheus.DefBuckets,
+	}, []string{"route", "method", "status"})
+
+	rateLimited = prometheus.NewCounter(prometheus.CounterOpts{
+		Namespace: namespace,
+		Subsystem: "http",
+		Name:      "rate_limited_total",
+		Help:      "HTTP requests rejected for exceeding the client quota.",
+	})
+
+	panics = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Subsystem: "http",
+		Name:      "panics_total",
+		Help:      "Handler panics recovered into 500 responses, by route.",
+	}, []string{"route"})
+
+	operations = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "operations_total",
+		Help:      "Completed money operations by type and currency.",
+	}, []string{"type", "currency"})
+
+	amounts = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "operation_amount_total",
+		Help:      "Money moved by completed operations, by type and currency.",
+	}, []string{"type", "currency"})
+
+	notEnoughMoney = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "insufficient_funds_total",
+		Help:      "Money operations rejected for lack of funds, by type.",
+	}, []string{"type"})
+
+	ratesAge = prometheus.NewGaugeFunc(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Subsystem: "exchange",
+		Name:      "rates_age_seconds",
+		Help:      "Age of the cached exchange rates, NaN before the first download.",
+	}, func() float64 {
+		age, ok := exchangerate.RatesAge()
+		if !ok {
+			return math.NaN()
+		}
+		return age.Seconds()
+	})
+
+	fetchFailures = prometheus.NewCounterFunc(prometheus.CounterOpts{
+		Namespace: namespace,
+		Subsystem: "exchange",
+		Name:      "rate_fetch_failures_total",
+		Help:      "Failed downloads from the exchange rate provider.",
+	}, func() float64 {
+		return float64(exchangerate.FetchFailures())
+	})
+)
+
+func init() {
+	Registry.MustRegister(
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+		requests, latency, rateLimited, panics,
+		operations, amounts, notEnoughMoney,
+		ratesAge, fetchFailures,
+	)
+}
+
+// Handler serves the metrics in the Prometheus text format.
+func Handler() http.Handler {
+	return promhttp.HandlerFor(Registry, promhttp.HandlerOpts{})
+}
+
+// RegisterDatabase exposes the pool stats of db. Registering the same pool
+// again is a no-op.
+func RegisterDatabase(db *sql.DB, name string) error {
+	err := Registry.Register(collectors.NewDBStatsCollector(db, name))
+	var registered prometheus.AlreadyRegisteredError
+	if errors.As(err, &registered) {
+		return nil
+	}
+	return err
+}
+
+func ObserveRequest(route, method string, status int, elapsed time.Duration) {
+	code := strconv.Itoa(status)
+	requests.WithLabelValues(route, method, code).Inc()
+	latency.WithLabelValues(route, method, code).Observe(elapsed.Seconds())
+}
+
+func RateLimited() {
+	rateLimited.Inc()
+}
+
+func Panicked(route string) {
+	panics.WithLabelValues(route).Inc()
+}
+
+// ObserveOperation counts a committed income, outcome or transfer of amount.
+func ObserveOperation(kind string, amount money.Money) {
+	operations.WithLabelValues(kind, amount.Currency).Inc()
+	amounts.WithLabelValues(kind, amount.Currency).Add(amount.Amount.InexactFloat64())
+}
+
+func NotEnoughMoney(kind string) {
+	notEnoughMoney.WithLabelValues(kind).Inc()
+}
@@ -0,0 +1,41 @@
+package metrics
+
+import (
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"job/domain/money"
+)
+
+func Test_Handler_ShouldReturn_SuccessResult(t *testing.T) {
+	amount, err := money.Parse("50", "RUB")
+	if err != nil {
+		t.Fatal(err)
+	}
+	ObserveOperation("income", amount)
+	ObserveRequest("/balances/{id}", "GET", http.StatusOK, 30*time.Millisecond)
+
+	rr := httptest.NewRecorder()
+	req := httptest.NewRequest("GET", "/metrics", nil)
+	Handler().ServeHTTP(rr, req)
+	if rr.Code != http.StatusOK {
+		log.Printf("Expected 200, but got %d\n", rr.Code)
+		t.Fatal(rr.Code)
+	}
+
+	for _, expected := range []string{
+		`balanceapp_operations_total{currency="RUB",type="income"} 1`,
+		`balanceapp_operation_amount_total{currency="RUB",type="income"} 50`,
+		`balanceapp_http_requests_total{method="GET",route="/balances/{id}",status="200"} 1`,
+		`balanceapp_exchange_rates_age_seconds NaN`,
+	} {
+		if !strings.Contains(rr.Body.String(), expected) {
+			log.Printf("Expected %s in\n%s\n", expected, rr.Body.String())
+			t.Fatal(expected)
+		}
+	}
+}
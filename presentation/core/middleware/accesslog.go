@@ -0,0 +1,169 @@
+package middleware
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math/rand/v2"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"job/presentation/core/logger"
+)
+
+const maskedValue = "***"
+
+// AccessLogSettings tell which requests to log and how much of them.
+// SampleRate is the share of successful requests logged, Routes sets it per
+// route template; failed requests are always logged. Request bodies are
+// logged only as JSON with the MaskFields values hidden.
+type AccessLogSettings struct {
+	Enabled      bool
+	SampleRate   float64
+	Routes       map[string]float64
+	LogBodies    bool
+	MaxBodyBytes int
+	MaskFields   []string
+}
+
+// AccessLog logs one line per request. Its settings can be replaced while it
+// serves.
+type AccessLog struct {
+	settings atomic.Pointer[AccessLogSettings]
+}
+
+func NewAccessLog(settings AccessLogSettings) *AccessLog {
+	accessLog := new(AccessLog)
+	accessLog.SetSettings(settings)
+	return accessLog
+}
+
+func (accessLog *AccessLog) SetSettings(settings AccessLogSettings) {
+	accessLog.settings.Store(&settings)
+}
+
+// Log wraps next with the access log. Put it after RequestID so the lines
+// carry the request id.
+func (accessLog *AccessLog) Log(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		settings := accessLog.settings.Load()
+		if !settings.Enabled {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		var tap *bodyTap
+		if settings.LogBodies && r.Body != nil {
+			tap = &bodyTap{ReadCloser: r.Body, max: settings.MaxBodyBytes}
+			r.Body = tap
+		}
+		start := time.Now()
+		sw := StatusRecorder{ResponseWriter: w, Status: http.StatusOK}
+		next.ServeHTTP(&sw, r)
+		elapsed := time.Since(start)
+
+		route := routeOf(r)
+		rate := settings.SampleRate
+		if routeRate, ok := settings.Routes[route]; ok {
+			rate = routeRate
+		}
+		if sw.Status < http.StatusBadRequest && rand.Float64() >= rate {
+			return
+		}
+
+		entry := logger.FromContext(r.Context()).With(
+			"Status", sw.Status,
+			"Bytes", sw.Bytes,
+			"LatencyMs", float64(elapsed.Microseconds())/1000,
+			"ClientIP", clientIP(r),
+		)
+		if tap != nil {
+			entry = entry.With("Body", tap.masked(settings.MaskFields))
+		}
+
+		message := r.Method + " " + route + " " + strconv.Itoa(sw.Status)
+		if sw.Status >= http.StatusInternalServerError {
+			entry.Error(message)
+			return
+		}
+		entry.Info(message)
+	})
+}
+
+func clientIP(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
+
+// bodyTap keeps the first max bytes of the request body as the handler
+// reads it.
+type bodyTap struct {
+	io.ReadCloser
+	max       int
+	read      int
+	buf       bytes.Buffer
+	truncated bool
+}
+
+func (tap *bodyTap) Read(p []byte) (int, error) {
+	n, err := tap.ReadCloser.Read(p)
+	tap.read += n
+	if room := tap.max - tap.buf.Len(); room < n {
+		tap.buf.Write(p[:room])
+		tap.truncated = true
+	} else {
+		tap.buf.Write(p[:n])
+	}
+	return n, err
+}
+
+// masked returns the body read so far with the values of fields hidden at
+// any depth. A body that isn't whole JSON isn't logged, since it can't be
+// masked.
+func (tap *bodyTap) masked(fields []string) string {
+	if tap.read == 0 {
+		return ""
+	}
+	var body interface{}
+	decoder := json.NewDecoder(bytes.NewReader(tap.buf.Bytes()))
+	decoder.UseNumber()
+	if tap.truncated || decoder.Decode(&body) != nil {
+		return fmt.Sprintf("<%d bytes not logged>", tap.read)
+	}
+
+	hidden := make(map[string]bool, len(fields))
+	for _, field := range fields {
+		hidden[strings.ToLower(field)] = true
+	}
+	data, err := json.Marshal(mask(body, hidden))
+	if err != nil {
+		return fmt.Sprintf("<%d bytes not logged>", tap.read)
+	}
+	return string(data)
+}
+
+func mask(value interface{}, hidden map[string]bool) interface{} {
+	switch value := value.(type) {
+	case map[string]interface{}:
+		for key, item := range value {
+			if hidden[strings.ToLower(key)] {
+				value[key] = maskedValue
+				continue
+			}
+			value[key] = mask(item, hidden)
+		}
+	case []interface{}:
+		for i, item := range value {
+			value[i] = mask(item, hidden)
+		}
+	}
+	return value
+}
@@ -0,0 +1,72 @@
+package middleware
+
+import (
+	"bytes"
+	"io"
+	"log"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func captureLog(t *testing.T) *bytes.Buffer {
+	var out bytes.Buffer
+	previous := slog.Default()
+	slog.SetDefault(slog.New(slog.NewTextHandler(&out, nil)))
+	t.Cleanup(func() { slog.SetDefault(previous) })
+	return &out
+}
+
+func Test_AccessLog_ShouldReturn_SuccessResultMasked(t *testing.T) {
+	out := captureLog(t)
+	accessLog := NewAccessLog(AccessLogSettings{
+		Enabled:      true,
+		SampleRate:   1,
+		LogBodies:    true,
+		MaxBodyBytes: 4096,
+		MaskFields:   []string{"amount", "reason"},
+	})
+	handler := accessLog.Log(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		io.ReadAll(r.Body)
+		w.Write([]byte(`"Done!"`))
+	}))
+
+	req := httptest.NewRequest("POST", "/balances/income", strings.NewReader(`{"toId":1,"amount":{"amount":"300","currency":"RUB"},"reason":"salary"}`))
+	handler.ServeHTTP(httptest.NewRecorder(), req)
+
+	line := out.String()
+	for _, expected := range []string{"POST unknown 200", "Bytes=7", `\"amount\":\"***\"`, `\"reason\":\"***\"`, `\"toId\":1`} {
+		if !strings.Contains(line, expected) {
+			log.Printf("Expected %s in %s\n", expected, line)
+			t.Fatal(expected)
+		}
+	}
+	if strings.Contains(line, "salary") || strings.Contains(line, "300") {
+		log.Printf("Expected masked values in %s\n", line)
+		t.Fatal(line)
+	}
+}
+
+func Test_AccessLog_ShouldReturn_SuccessResultSampled(t *testing.T) {
+	out := captureLog(t)
+	status := http.StatusOK
+	accessLog := NewAccessLog(AccessLogSettings{Enabled: true, SampleRate: 0})
+	handler := accessLog.Log(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(status)
+	}))
+
+	handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/balances/1", nil))
+	if out.Len() != 0 {
+		log.Printf("Expected no line, but got %s\n", out.String())
+		t.Fatal(out.String())
+	}
+
+	status = http.StatusBadRequest
+	handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/balances/-1", nil))
+	if !strings.Contains(out.String(), "GET unknown 400") {
+		log.Printf("Expected the failed request, but got %s\n", out.String())
+		t.Fatal(out.String())
+	}
+}
@@ -0,0 +1,149 @@
+package middleware
+
+import (
+	"context"
+	"net/http"
+	"strings"
+	"sync/atomic"
+
+	"job/presentation/core/auth"
+	"job/presentation/core/logger"
+	"job/presentation/core/rfc7807"
+)
+
+// APIKeyHeader carries the API key of a client that doesn't send it as a
+// bearer token.
+const APIKeyHeader = "X-API-Key"
+
+// ClientLookup finds the client whose key hashes to keyHash, nil if there is
+// none.
+type ClientLookup func(ctx context.Context, keyHash string) (*auth.Client, error)
+
+// TokenVerifier checks a bearer JWT and returns the client it was issued
+// for.
+type TokenVerifier func(ctx context.Context, token string) (*auth.Client, error)
+
+// Authenticator checks API keys against the clients from config first, then
+// against lookup, and bearer JWTs with verify. The clients can be replaced
+// while it serves.
+type Authenticator struct {
+	clients atomic.Pointer[authClients]
+	lookup  ClientLookup
+	verify  TokenVerifier
+}
+
+type authClients struct {
+	enabled bool
+	byHash  map[string]auth.Client
+}
+
+func NewAuthenticator(enabled bool, clients map[string]auth.Client, lookup ClientLookup) *Authenticator {
+	authenticator := &Authenticator{lookup: lookup}
+	authenticator.SetClients(enabled, clients)
+	return authenticator
+}
+
+// SetVerifier makes the authenticator accept bearer JWTs checked by verify.
+func (authenticator *Authenticator) SetVerifier(verify TokenVerifier) *Authenticator {
+	authenticator.verify = verify
+	return authenticator
+}
+
+// SetClients replaces the clients from config, keyed by key hash. With
+// enabled false every request is let through unauthenticated.
+func (authenticator *Authenticator) SetClients(enabled bool, clients map[string]auth.Client) {
+	authenticator.clients.Store(&authClients{enabled: enabled, byHash: clients})
+}
+
+// Require lets through only requests whose API key or bearer JWT belongs to
+// a client with scope, the empty scope admitting any known client. An
+// unknown key or invalid token gets 401, a known one without the scope 403.
+// The client is put in the request context and its id in the request log.
+func (authenticator *Authenticator) Require(scope string, next http.HandlerFunc) http.HandlerFunc {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		clients := authenticator.clients.Load()
+		if !clients.enabled {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		key := r.Header.Get(APIKeyHeader)
+		if bearer := r.Header.Get("Authorization"); key == "" && strings.HasPrefix(bearer, "Bearer ") {
+			key = strings.TrimPrefix(bearer, "Bearer ")
+		}
+		if authenticator.verify != nil && strings.Count(key, ".") == 2 {
+			client, err := authenticator.verify(r.Context(), key)
+			if err != nil {
+				w.Header().Set("WWW-Authenticate", `Bearer realm="balanceapp", error="invalid_token"`)
+				problem := rfc7807.NewProblem().
+					AppendError("Authorization", "Bearer token is invalid or expired!").
+					SetType("auth").
+					SetStatus(http.StatusUnauthorized)
+				logger.FromContext(r.Context()).Info("Bearer token rejected: " + err.Error())
+				if err := problem.Write(w); err != nil {
+					return
+				}
+				return
+			}
+			authenticator.admit(scope, client, next, w, r)
+			return
+		}
+
+		client, err := authenticator.find(r.Context(), clients, key)
+		if err != nil {
+			problem := rfc7807.NewProblem().
+				AppendError("Server", "Internal server error!").
+				SetType("server").
+				SetStatus(http.StatusInternalServerError)
+			logger.FromContext(r.Context()).Error(err.Error())
+			if err := problem.Write(w); err != nil {
+				return
+			}
+			return
+		}
+		if client == nil {
+			w.Header().Set("WWW-Authenticate", `Bearer realm="balanceapp"`)
+			problem := rfc7807.NewProblem().
+				AppendError("Authorization", "API key is missing or unknown!").
+				SetType("auth").
+				SetStatus(http.StatusUnauthorized)
+			if err := problem.Write(w); err != nil {
+				return
+			}
+			return
+		}
+
+		authenticator.admit(scope, client, next, w, r)
+	})
+}
+
+// admit passes the request of a known client on if it has scope.
+func (authenticator *Authenticator) admit(scope string, client *auth.Client, next http.HandlerFunc, w http.ResponseWriter, r *http.Request) {
+	logger.Annotate(r.Context(), "ClientID", client.ID)
+	if !client.Can(scope) {
+		problem := rfc7807.NewProblem().
+			AppendError("Authorization", "Client has no "+scope+" scope!").
+			SetType("auth").
+			SetStatus(http.StatusForbidden)
+		logger.FromContext(r.Context()).Info("Client " + client.ID + " has no " + scope + " scope")
+		if err := problem.Write(w); err != nil {
+			return
+		}
+		return
+	}
+	next.ServeHTTP(w, r.WithContext(auth.NewContext(r.Context(), *client)))
+}
+
+func (authenticator *Authenticator) find(ctx context.Context, clients *authClients, key string) (*auth.Client, error) {
+	if key == "" {
+		return nil, nil
+	}
+	hash := auth.HashKey(key)
+	if client, ok := clients.byHash[hash]; ok {
+		return &client, nil
+	}
+	if authenticator.lookup == nil {
+		return nil, nil
+	}
+	return authenticator.lookup(ctx, hash)
+}
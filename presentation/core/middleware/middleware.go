@@ -2,21 +2,51 @@ package middleware
 
 import (
 	"net/http"
+	"time"
+
+	"job/presentation/core/metrics"
+
+	"github.com/gorilla/mux"
 )
 
+// Requests records the status and latency of each request under its route
+// template, so /balances/1 and /balances/2 count as one route.
 func Requests(next http.HandlerFunc) http.HandlerFunc {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
 		sw := StatusRecorder{ResponseWriter: w, Status: http.StatusOK}
 		next.ServeHTTP(&sw, r)
+		metrics.ObserveRequest(routeOf(r), r.Method, sw.Status, time.Since(start))
 	})
 }
 
+func routeOf(r *http.Request) string {
+	if route := mux.CurrentRoute(r); route != nil {
+		if template, err := route.GetPathTemplate(); err == nil {
+			return template
+		}
+	}
+	return "unknown"
+}
+
+// StatusRecorder remembers the status and the size of a response body.
+// Written tells whether the status has been sent.
 type StatusRecorder struct {
 	http.ResponseWriter
-	Status int
+	Status  int
+	Bytes   int
+	Written bool
 }
 
 func (rec *StatusRecorder) WriteHeader(code int) {
 	rec.Status = code
+	rec.Written = true
 	rec.ResponseWriter.WriteHeader(code)
 }
+
+func (rec *StatusRecorder) Write(data []byte) (int, error) {
+	rec.Written = true
+	n, err := rec.ResponseWriter.Write(data)
+	rec.Bytes += n
+	return n, err
+}
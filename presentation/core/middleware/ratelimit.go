@@ -0,0 +1,102 @@
+package middleware
+
+import (
+	"math"
+	"net"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"job/presentation/core/metrics"
+	"job/presentation/core/rfc7807"
+
+	"golang.org/x/time/rate"
+)
+
+// rateClientIdle is how long a client's bucket is kept after its last request.
+const rateClientIdle = 10 * time.Minute
+
+// RateLimiter keeps a token bucket per client address. The quota can be
+// changed while requests are served.
+type RateLimiter struct {
+	mu      sync.Mutex
+	limit   rate.Limit
+	burst   int
+	clients map[string]*rateClient
+	swept   time.Time
+}
+
+type rateClient struct {
+	limiter *rate.Limiter
+	seen    time.Time
+}
+
+func NewRateLimiter(perSecond float64, burst int) *RateLimiter {
+	limiter := &RateLimiter{clients: make(map[string]*rateClient)}
+	limiter.SetQuota(perSecond, burst)
+	return limiter
+}
+
+// SetQuota applies a new quota to every client, perSecond of zero lifts it.
+func (limiter *RateLimiter) SetQuota(perSecond float64, burst int) {
+	limiter.mu.Lock()
+	defer limiter.mu.Unlock()
+	limiter.limit, limiter.burst = rate.Limit(perSecond), burst
+	for _, client := range limiter.clients {
+		client.limiter.SetLimit(limiter.limit)
+		client.limiter.SetBurst(burst)
+	}
+}
+
+// allow takes a token of client, or tells how long to wait for one.
+func (limiter *RateLimiter) allow(client string, now time.Time) (bool, time.Duration) {
+	limiter.mu.Lock()
+	defer limiter.mu.Unlock()
+	if limiter.limit <= 0 {
+		return true, 0
+	}
+
+	if now.Sub(limiter.swept) > rateClientIdle {
+		for key, idle := range limiter.clients {
+			if now.Sub(idle.seen) > rateClientIdle {
+				delete(limiter.clients, key)
+			}
+		}
+		limiter.swept = now
+	}
+
+	bucket, ok := limiter.clients[client]
+	if !ok {
+		bucket = &rateClient{limiter: rate.NewLimiter(limiter.limit, limiter.burst)}
+		limiter.clients[client] = bucket
+	}
+	bucket.seen = now
+	if bucket.limiter.AllowN(now, 1) {
+		return true, 0
+	}
+	return false, time.Duration(float64(time.Second) / float64(limiter.limit))
+}
+
+func (limiter *RateLimiter) Limit(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		client, _, err := net.SplitHostPort(r.RemoteAddr)
+		if err != nil {
+			client = r.RemoteAddr
+		}
+
+		if ok, wait := limiter.allow(client, time.Now()); !ok {
+			metrics.RateLimited()
+			w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(wait.Seconds()))))
+			problem := rfc7807.NewProblem().
+				AppendError("client", "Too many requests, slow down!").
+				SetType("limit").
+				SetStatus(http.StatusTooManyRequests)
+			if err := problem.Write(w); err != nil {
+				return
+			}
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
@@ -0,0 +1,49 @@
+package middleware
+
+import (
+	"fmt"
+	"net/http"
+	"runtime/debug"
+
+	"job/presentation/core/logger"
+	"job/presentation/core/metrics"
+	"job/presentation/core/rfc7807"
+)
+
+// Recover turns a handler panic into a 500 problem carrying the request id,
+// logs it critical with the stack and counts it. Put it after RequestID and
+// AccessLog so the line and the access log see the request id and the 500.
+// http.ErrAbortHandler is passed on, it is how a handler aborts on purpose.
+func Recover(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		sw := StatusRecorder{ResponseWriter: w, Status: http.StatusOK}
+		defer func() {
+			recovered := recover()
+			if recovered == nil {
+				return
+			}
+			if recovered == http.ErrAbortHandler {
+				panic(recovered)
+			}
+
+			route := routeOf(r)
+			metrics.Panicked(route)
+			logger.FromContext(r.Context()).Log(r.Context(), logger.LevelCritical,
+				fmt.Sprintf("Panic in %s %s: %v", r.Method, route, recovered),
+				"Stack", string(debug.Stack()))
+
+			if sw.Written {
+				// The client already has a status, the response can only be cut.
+				panic(http.ErrAbortHandler)
+			}
+			problem := rfc7807.NewProblem().
+				AppendError("Server", "Internal server error!").
+				SetType("server").
+				SetStatus(http.StatusInternalServerError)
+			if err := problem.Write(&sw); err != nil {
+				return
+			}
+		}()
+		next.ServeHTTP(&sw, r)
+	})
+}
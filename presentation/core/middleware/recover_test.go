@@ -0,0 +1,46 @@
+package middleware
+
+import (
+	"encoding/json"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"job/presentation/core/rfc7807"
+)
+
+func Test_Recover_ShouldReturn_ErrorResultProblem(t *testing.T) {
+	out := captureLog(t)
+	handler := RequestID(nil)(Recover(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var amount *int
+		_ = *amount
+	})))
+
+	req := httptest.NewRequest("GET", "/balances/1", nil)
+	req.Header.Set(RequestIDHeader, "incident-42")
+	rr := httptest.NewRecorder()
+	handler.ServeHTTP(rr, req)
+
+	if rr.Code != http.StatusInternalServerError {
+		log.Printf("Expected 500, but got %d\n", rr.Code)
+		t.Fatal(rr.Code)
+	}
+	if ctype := rr.Header().Get("Content-Type"); ctype != "application/problem+json" {
+		log.Printf("Expected application/problem+json, but got %s\n", ctype)
+		t.Fatal(ctype)
+	}
+	var problem rfc7807.Problem
+	if err := json.Unmarshal(rr.Body.Bytes(), &problem); err != nil {
+		t.Fatal(err)
+	}
+	if problem.Instance == nil || *problem.Instance != "incident-42" {
+		log.Printf("Expected instance incident-42, but got %v\n", problem.Instance)
+		t.Fatal(problem.Instance)
+	}
+	if line := out.String(); !strings.Contains(line, "nil pointer dereference") || !strings.Contains(line, "Stack=") {
+		log.Printf("Expected the panic with its stack, but got %s\n", line)
+		t.Fatal(line)
+	}
+}
@@ -0,0 +1,57 @@
+package middleware
+
+import (
+	"log/slog"
+	"net/http"
+
+	"job/presentation/core/logger"
+
+	"github.com/gorilla/mux"
+	"github.com/segmentio/ksuid"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// RequestIDHeader carries the id that ties a request to its log lines.
+const RequestIDHeader = "X-Request-ID"
+
+const maxRequestIDLength = 128
+
+// RequestID takes the request id from the client or makes one up, echoes it
+// in the response and puts a logger carrying it, the route, the method and
+// the trace id in the request context. Put it after Trace.
+func RequestID(log *slog.Logger) mux.MiddlewareFunc {
+	if log == nil {
+		log = slog.Default()
+	}
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			id := r.Header.Get(RequestIDHeader)
+			if !validRequestID(id) {
+				id = ksuid.New().String()
+			}
+			w.Header().Set(RequestIDHeader, id)
+
+			entry := log.With("RequestID", id, "Route", routeOf(r), "Method", r.Method)
+			if span := trace.SpanContextFromContext(r.Context()); span.IsValid() {
+				entry = entry.With("TraceID", span.TraceID().String())
+			}
+			next.ServeHTTP(w, r.WithContext(logger.NewContext(r.Context(), entry)))
+		})
+	}
+}
+
+// validRequestID accepts ids that are safe to log and to echo in a header.
+func validRequestID(id string) bool {
+	if id == "" || len(id) > maxRequestIDLength {
+		return false
+	}
+	for _, c := range id {
+		switch {
+		case c >= 'a' && c <= 'z', c >= 'A' && c <= 'Z', c >= '0' && c <= '9':
+		case c == '-', c == '_', c == '.', c == ':':
+		default:
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,56 @@
+package middleware
+
+import (
+	"encoding/json"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"job/presentation/core/rfc7807"
+)
+
+func Test_RequestID_ShouldReturn_SuccessResultEcho(t *testing.T) {
+	handler := RequestID(nil)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		problem := rfc7807.NewProblem().
+			AppendError("Id", "Id must be positive integer!").
+			SetType("business").
+			SetStatus(http.StatusBadRequest)
+		if err := problem.Write(w); err != nil {
+			return
+		}
+	}))
+
+	req := httptest.NewRequest("GET", "/balances/-1", nil)
+	req.Header.Set(RequestIDHeader, "incident-42")
+	rr := httptest.NewRecorder()
+	handler.ServeHTTP(rr, req)
+
+	if id := rr.Header().Get(RequestIDHeader); id != "incident-42" {
+		log.Printf("Expected incident-42, but got %s\n", id)
+		t.Fatal(id)
+	}
+	var problem rfc7807.Problem
+	if err := json.Unmarshal(rr.Body.Bytes(), &problem); err != nil {
+		t.Fatal(err)
+	}
+	if problem.Instance == nil || *problem.Instance != "incident-42" {
+		log.Printf("Expected instance incident-42, but got %v\n", problem.Instance)
+		t.Fatal(problem.Instance)
+	}
+}
+
+func Test_RequestID_ShouldReturn_SuccessResultGenerated(t *testing.T) {
+	handler := RequestID(nil)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+
+	req := httptest.NewRequest("GET", "/balances/1", nil)
+	req.Header.Set(RequestIDHeader, "bad id\r\nX-Injected: 1")
+	rr := httptest.NewRecorder()
+	handler.ServeHTTP(rr, req)
+
+	id := rr.Header().Get(RequestIDHeader)
+	if id == "" || id == req.Header.Get(RequestIDHeader) {
+		log.Printf("Expected a new request id, but got %q\n", id)
+		t.Fatal(id)
+	}
+}
@@ -0,0 +1,185 @@
+package middleware
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"job/presentation/core/auth"
+	"job/presentation/core/logger"
+	"job/presentation/core/rfc7807"
+)
+
+// The headers of a signed request. SignatureClientHeader names the client
+// only when the request isn't authenticated otherwise.
+const (
+	SignatureHeader          = "X-Signature"
+	SignatureTimestampHeader = "X-Signature-Timestamp"
+	SignatureNonceHeader     = "X-Signature-Nonce"
+	SignatureClientHeader    = "X-Signature-Client"
+)
+
+// Signing modes of a route: a required signature must be there, an optional
+// one is checked only if it is.
+const (
+	SignatureRequired = "required"
+	SignatureOptional = "optional"
+)
+
+// SignatureSettings name the routes, by template, that take signed requests
+// and the shared secret of each client. Timestamps may be MaxSkew away from
+// the server clock.
+type SignatureSettings struct {
+	Routes       map[string]string
+	Secrets      map[string]string
+	MaxSkew      time.Duration
+	MaxBodyBytes int64
+}
+
+// Signatures checks HMAC-SHA256 request signatures and remembers the nonces
+// seen within the skew window, so a signed request can't be replayed to this
+// instance. The nonces aren't shared: behind a load balancer a captured
+// request can still be replayed once to every other instance for 2*MaxSkew.
+// Its settings can be replaced while it serves.
+type Signatures struct {
+	settings atomic.Pointer[SignatureSettings]
+
+	mu     sync.Mutex
+	nonces map[string]time.Time
+	swept  time.Time
+}
+
+func NewSignatures(settings SignatureSettings) *Signatures {
+	signatures := &Signatures{nonces: make(map[string]time.Time)}
+	signatures.SetSettings(settings)
+	return signatures
+}
+
+func (signatures *Signatures) SetSettings(settings SignatureSettings) {
+	signatures.settings.Store(&settings)
+}
+
+// Sign is the hex HMAC-SHA256 with secret of the method, the path with its
+// query, the timestamp, the nonce and the hex SHA-256 of the body, one per
+// line.
+func Sign(secret, method, path, timestamp, nonce string, body []byte) string {
+	bodyHash := sha256.Sum256(body)
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(strings.Join([]string{method, path, timestamp, nonce, hex.EncodeToString(bodyHash[:])}, "\n")))
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// Verify wraps next with the signature check of its route. Put it after the
+// authenticator, whose client signs the request.
+func (signatures *Signatures) Verify(next http.HandlerFunc) http.HandlerFunc {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		settings := signatures.settings.Load()
+		mode := settings.Routes[routeOf(r)]
+		signature := r.Header.Get(SignatureHeader)
+		if mode != SignatureRequired && (mode != SignatureOptional || signature == "") {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		if err := signatures.check(r, settings, signature, time.Now()); err != nil {
+			problem := rfc7807.NewProblem().
+				AppendError("Signature", err.Error()).
+				SetType("auth").
+				SetStatus(http.StatusUnauthorized)
+			logger.FromContext(r.Context()).Info(err.Error())
+			if err := problem.Write(w); err != nil {
+				return
+			}
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// check verifies the signature of r and takes up its nonce. The body is read
+// and put back for the handler.
+func (signatures *Signatures) check(r *http.Request, settings *SignatureSettings, signature string, now time.Time) error {
+	timestamp := r.Header.Get(SignatureTimestampHeader)
+	nonce := r.Header.Get(SignatureNonceHeader)
+	if signature == "" || timestamp == "" || nonce == "" {
+		return errors.New("Request must be signed!")
+	}
+	if len(nonce) < 8 || len(nonce) > 128 {
+		return errors.New("Signature nonce must be 8 to 128 characters!")
+	}
+	seconds, err := strconv.ParseInt(timestamp, 10, 64)
+	if err != nil {
+		return errors.New("Signature timestamp must be Unix seconds!")
+	}
+	if skew := now.Sub(time.Unix(seconds, 0)); skew > settings.MaxSkew || skew < -settings.MaxSkew {
+		return errors.New("Signature timestamp is outside the allowed window!")
+	}
+
+	clientID := r.Header.Get(SignatureClientHeader)
+	if client, ok := auth.FromContext(r.Context()); ok {
+		clientID = client.ID
+	}
+	secret, ok := settings.Secrets[clientID]
+	if clientID == "" || !ok {
+		return errors.New("Client has no signing secret!")
+	}
+
+	var body []byte
+	if r.Body != nil {
+		limit := settings.MaxBodyBytes
+		if limit <= 0 {
+			limit = 1 << 20
+		}
+		body, err = io.ReadAll(io.LimitReader(r.Body, limit+1))
+		if err != nil {
+			return errors.New("Request body can't be read!")
+		}
+		if int64(len(body)) > limit {
+			return errors.New("Request body is too large to sign!")
+		}
+		r.Body = io.NopCloser(bytes.NewReader(body))
+	}
+
+	path := r.URL.EscapedPath()
+	if r.URL.RawQuery != "" {
+		path += "?" + r.URL.RawQuery
+	}
+	expected := Sign(secret, r.Method, path, timestamp, nonce, body)
+	if !hmac.Equal([]byte(expected), []byte(strings.ToLower(signature))) {
+		return errors.New("Signature is invalid!")
+	}
+	if !signatures.useNonce(clientID+" "+nonce, now, settings.MaxSkew) {
+		return errors.New("Signature nonce was already used!")
+	}
+	return nil
+}
+
+// useNonce records nonce and tells whether it is new. A nonce is kept as long
+// as a request carrying it could still pass the timestamp check.
+func (signatures *Signatures) useNonce(nonce string, now time.Time, maxSkew time.Duration) bool {
+	signatures.mu.Lock()
+	defer signatures.mu.Unlock()
+
+	if now.Sub(signatures.swept) > maxSkew {
+		for seen, expires := range signatures.nonces {
+			if now.After(expires) {
+				delete(signatures.nonces, seen)
+			}
+		}
+		signatures.swept = now
+	}
+	if expires, ok := signatures.nonces[nonce]; ok && now.Before(expires) {
+		return false
+	}
+	signatures.nonces[nonce] = now.Add(2 * maxSkew)
+	return true
+}
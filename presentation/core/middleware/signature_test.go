@@ -0,0 +1,111 @@
+package middleware
+
+import (
+	"io"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"job/presentation/core/auth"
+)
+
+const testSecret = "0123456789abcdef0123456789abcdef"
+
+func signedRequest(body, timestamp, nonce, secret string) *http.Request {
+	req := httptest.NewRequest("POST", "/balances/income", strings.NewReader(body))
+	req.Header.Set(SignatureClientHeader, "gateway")
+	req.Header.Set(SignatureTimestampHeader, timestamp)
+	req.Header.Set(SignatureNonceHeader, nonce)
+	req.Header.Set(SignatureHeader, Sign(secret, "POST", "/balances/income", timestamp, nonce, []byte(body)))
+	return req
+}
+
+func Test_Signatures_ShouldReturn_SuccessResult(t *testing.T) {
+	signatures := NewSignatures(SignatureSettings{
+		Routes:  map[string]string{"unknown": SignatureRequired},
+		Secrets: map[string]string{"gateway": testSecret},
+		MaxSkew: time.Minute,
+	})
+	var read string
+	handler := signatures.Verify(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		read = string(body)
+	})
+
+	body := `{"toId":1,"amount":{"amount":"300","currency":"RUB"}}`
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, signedRequest(body, strconv.FormatInt(time.Now().Unix(), 10), "nonce-0001", testSecret))
+	if rec.Code != http.StatusOK || read != body {
+		log.Printf("Expected the signed body passed on, but got %d %q\n", rec.Code, read)
+		t.Fatal(rec.Body.String())
+	}
+}
+
+func Test_Signatures_ShouldReturn_ErrorResult(t *testing.T) {
+	signatures := NewSignatures(SignatureSettings{
+		Routes:  map[string]string{"unknown": SignatureRequired},
+		Secrets: map[string]string{"gateway": testSecret},
+		MaxSkew: time.Minute,
+	})
+	handler := signatures.Verify(func(w http.ResponseWriter, r *http.Request) {})
+	now := strconv.FormatInt(time.Now().Unix(), 10)
+	stale := strconv.FormatInt(time.Now().Add(-2*time.Minute).Unix(), 10)
+
+	tampered := signedRequest(`{"toId":1}`, now, "nonce-0002", testSecret)
+	tampered.Body = io.NopCloser(strings.NewReader(`{"toId":2}`))
+	unsigned := httptest.NewRequest("POST", "/balances/income", strings.NewReader(`{}`))
+
+	for name, req := range map[string]*http.Request{
+		"unsigned":    unsigned,
+		"tampered":    tampered,
+		"stale":       signedRequest(`{}`, stale, "nonce-0003", testSecret),
+		"wrong key":   signedRequest(`{}`, now, "nonce-0004", strings.Repeat("x", 32)),
+		"short nonce": signedRequest(`{}`, now, "n", testSecret),
+	} {
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+		if rec.Code != http.StatusUnauthorized {
+			log.Printf("Expected 401 for the %s request, but got %d\n", name, rec.Code)
+			t.Fatal(name)
+		}
+	}
+
+	replayed := httptest.NewRecorder()
+	handler.ServeHTTP(httptest.NewRecorder(), signedRequest(`{}`, now, "nonce-0005", testSecret))
+	handler.ServeHTTP(replayed, signedRequest(`{}`, now, "nonce-0005", testSecret))
+	if replayed.Code != http.StatusUnauthorized || !strings.Contains(replayed.Body.String(), "already used") {
+		log.Printf("Expected the replayed nonce rejected, but got %d %s\n", replayed.Code, replayed.Body.String())
+		t.Fatal(replayed.Body.String())
+	}
+}
+
+func Test_Signatures_ShouldReturn_SuccessResultOptional(t *testing.T) {
+	signatures := NewSignatures(SignatureSettings{
+		Routes:  map[string]string{"unknown": SignatureOptional},
+		Secrets: map[string]string{"gateway": testSecret},
+		MaxSkew: time.Minute,
+	})
+	handler := signatures.Verify(func(w http.ResponseWriter, r *http.Request) {})
+
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest("POST", "/balances/income", strings.NewReader(`{}`)))
+	if rec.Code != http.StatusOK {
+		log.Printf("Expected an unsigned request let through, but got %d\n", rec.Code)
+		t.Fatal(rec.Body.String())
+	}
+
+	// The authenticated client signs, whatever the header says.
+	req := signedRequest(`{}`, strconv.FormatInt(time.Now().Unix(), 10), "nonce-0006", testSecret)
+	req.Header.Set(SignatureClientHeader, "someone")
+	req = req.WithContext(auth.NewContext(req.Context(), auth.Client{ID: "gateway"}))
+	rec = httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+	if rec.Code != http.StatusOK {
+		log.Printf("Expected the signature of the authenticated client, but got %d %s\n", rec.Code, rec.Body.String())
+		t.Fatal(rec.Body.String())
+	}
+}
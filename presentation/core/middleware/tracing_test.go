@@ -0,0 +1,57 @@
+package middleware
+
+import (
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"job/domain/repository"
+	"job/presentation/core/tracing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"go.opentelemetry.io/otel"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+)
+
+func Test_Trace_ShouldReturn_SuccessResultSpans(t *testing.T) {
+	exporter := tracetest.NewInMemoryExporter()
+	otel.SetTracerProvider(tracing.NewProvider(sdktrace.WithSyncer(exporter), tracing.Settings{SampleRatio: 1}))
+
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer db.Close()
+	mock.ExpectQuery("^SELECT (.+) FROM balances WHERE").WithArgs(1).WillReturnRows(sqlmock.NewRows([]string{"id", "balance", "currency"}).AddRow(1, "100", "RUB"))
+
+	handler := Trace(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if _, err := repository.GetBalancePg(r.Context(), db, 1); err != nil {
+			t.Fatal(err)
+		}
+	}))
+	req := httptest.NewRequest("GET", "/balances/1", nil)
+	req.Header.Set("traceparent", "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01")
+	handler.ServeHTTP(httptest.NewRecorder(), req)
+
+	spans := exporter.GetSpans()
+	if len(spans) != 3 {
+		log.Printf("Expected 3 spans, but got %d\n", len(spans))
+		t.Fatal(len(spans))
+	}
+	names := map[string]bool{}
+	for _, span := range spans {
+		names[span.Name] = true
+		if span.SpanContext.TraceID().String() != "4bf92f3577b34da6a3ce929d0e0e4736" {
+			log.Printf("Expected the incoming trace, but got %s\n", span.SpanContext.TraceID())
+			t.Fatal(span.SpanContext.TraceID())
+		}
+	}
+	for _, name := range []string{"GET unknown", "repository.GetBalancePg", "sql.query"} {
+		if !names[name] {
+			log.Printf("Expected span %s, but got %v\n", name, names)
+			t.Fatal(name)
+		}
+	}
+}
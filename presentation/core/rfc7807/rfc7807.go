@@ -43,13 +43,23 @@ func (problem *Problem) SetStatus(status int) *Problem {
 	return problem
 }
 
+func (problem *Problem) SetInstance(instance string) *Problem {
+	problem.Instance = &instance
+	return problem
+}
+
+// Write sends the problem. With no instance set, the request id the
+// middleware put in the response headers identifies the occurrence.
 func (problem *Problem) Write(w http.ResponseWriter) error {
+	if id := w.Header().Get("X-Request-ID"); problem.Instance == nil && id != "" {
+		problem.SetInstance(id)
+	}
 	body, err := json.Marshal(problem)
 	if err != nil {
 		return err
 	}
-	w.WriteHeader(problem.Status)
 	w.Header().Set("Content-Type", "application/problem+json")
+	w.WriteHeader(problem.Status)
 	if _, err := w.Write(body); err != nil {
 		return err
 	}
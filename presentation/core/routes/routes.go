@@ -1,9 +1,16 @@
 package routes
 
 import (
+	"context"
+	"net/http"
+	"strings"
+
 	"job/presentation/controller"
 
 	"job/domain/models"
+	"job/presentation/core/auth"
+	"job/presentation/core/config"
+	"job/presentation/core/metrics"
 	"job/presentation/core/middleware"
 
 	"github.com/gorilla/mux"
@@ -12,11 +19,111 @@ import (
 func NewRouter(env *controller.Environment, conf *models.Config) (*mux.Router, error) {
 	r := mux.NewRouter().StrictSlash(false)
 
-	r.HandleFunc("/balances/{id}", middleware.Requests(env.GetBalance)).Methods("GET")
-	r.HandleFunc("/balances/history/{id}", middleware.Requests(env.GetHistory)).Methods("GET")
-	r.HandleFunc("/balances/transfer", middleware.Requests(env.TransferTransaction)).Methods("POST")
-	r.HandleFunc("/balances/income", middleware.Requests(env.IncomeTransaction)).Methods("POST")
-	r.HandleFunc("/balances/outcome", middleware.Requests(env.OutcomeTransaction)).Methods("POST")
+	r.Use(middleware.Trace)
+	r.Use(middleware.RequestID(env.Logger()))
+
+	accessLog := middleware.NewAccessLog(accessLogSettings(conf))
+	config.OnReload(func(conf *models.Config) error {
+		accessLog.SetSettings(accessLogSettings(conf))
+		return nil
+	})
+	r.Use(accessLog.Log)
+	r.Use(middleware.Recover)
+
+	limiter := middleware.NewRateLimiter(conf.RateLimit.RequestsPerSecond, conf.RateLimit.Burst)
+	config.OnReload(func(conf *models.Config) error {
+		limiter.SetQuota(conf.RateLimit.RequestsPerSecond, conf.RateLimit.Burst)
+		return nil
+	})
+	r.Use(limiter.Limit)
+
+	if err := metrics.RegisterDatabase(env.Balances, conf.Database.Name); err != nil {
+		return nil, err
+	}
+	r.Handle("/metrics", metrics.Handler()).Methods("GET")
+	r.HandleFunc("/healthz", middleware.Requests(env.GetHealth)).Methods("GET")
+	r.HandleFunc("/readyz", middleware.Requests(env.GetReadiness)).Methods("GET")
+	r.HandleFunc("/version", middleware.Requests(env.GetVersion)).Methods("GET")
+
+	authenticator := middleware.NewAuthenticator(conf.Auth.Enabled, authClients(conf), env.LookupClient)
+	config.OnReload(func(conf *models.Config) error {
+		authenticator.SetClients(conf.Auth.Enabled, authClients(conf))
+		return nil
+	})
+	if conf.JWT.Enabled {
+		verifier, err := auth.NewVerifier(context.Background(), auth.VerifierSettings{
+			JWKSFile:        conf.JWT.JWKSFile,
+			JWKSURL:         conf.JWT.JWKSURL,
+			Issuer:          conf.JWT.Issuer,
+			Audience:        conf.JWT.Audience,
+			Leeway:          conf.JWT.Leeway.Duration,
+			RefreshInterval: conf.JWT.RefreshInterval.Duration,
+		})
+		if err != nil {
+			return nil, err
+		}
+		authenticator.SetVerifier(verifier.Verify)
+	}
+
+	signatures := middleware.NewSignatures(signatureSettings(conf))
+	config.OnReload(func(conf *models.Config) error {
+		signatures.SetSettings(signatureSettings(conf))
+		return nil
+	})
+	// Signatures are checked after authentication, by the secret of the
+	// authenticated client.
+	require := func(scope string, next http.HandlerFunc) http.HandlerFunc {
+		return authenticator.Require(scope, signatures.Verify(next))
+	}
+
+	r.HandleFunc("/balances/{id}", middleware.Requests(require(auth.ScopeBalancesRead, env.GetBalance))).Methods("GET")
+	r.HandleFunc("/balances/history/{id}", middleware.Requests(require(auth.ScopeHistoryRead, env.GetHistory))).Methods("GET")
+	r.HandleFunc("/balances/transfer", middleware.Requests(require(auth.ScopeTransferWrite, env.TransferTransaction))).Methods("POST")
+	r.HandleFunc("/balances/income", middleware.Requests(require(auth.ScopeIncomeWrite, env.IncomeTransaction))).Methods("POST")
+	r.HandleFunc("/balances/outcome", middleware.Requests(require(auth.ScopeOutcomeWrite, env.OutcomeTransaction))).Methods("POST")
+	r.HandleFunc("/currencies", middleware.Requests(require("", env.GetCurrencies))).Methods("GET")
+	r.HandleFunc("/quotes", middleware.Requests(require(auth.ScopeTransferWrite, env.CreateQuote))).Methods("POST")
+	r.HandleFunc("/rates", middleware.Requests(require("", env.GetRates))).Methods("GET")
+
+	r.HandleFunc("/admin/config", middleware.Requests(require(auth.ScopeAdmin, env.GetConfigVersion))).Methods("GET")
+	r.HandleFunc("/admin/rates/overrides", middleware.Requests(require(auth.ScopeAdmin, env.GetRateOverrides))).Methods("GET")
+	r.HandleFunc("/admin/rates/overrides", middleware.Requests(require(auth.ScopeAdmin, env.PutRateOverride))).Methods("PUT")
+	r.HandleFunc("/admin/rates/overrides/{base}/{currency}", middleware.Requests(require(auth.ScopeAdmin, env.DeleteRateOverride))).Methods("DELETE")
 
 	return r, nil
 }
+
+// authClients keys the clients from config by key hash.
+func authClients(conf *models.Config) map[string]auth.Client {
+	clients := make(map[string]auth.Client, len(conf.Auth.Clients))
+	for _, client := range conf.Auth.Clients {
+		clients[strings.ToLower(client.KeyHash)] = auth.Client{ID: client.ID, Scopes: client.Scopes}
+	}
+	return clients
+}
+
+func signatureSettings(conf *models.Config) middleware.SignatureSettings {
+	return middleware.SignatureSettings{
+		Routes:       conf.Signing.Routes,
+		Secrets:      conf.Signing.Secrets,
+		MaxSkew:      conf.Signing.MaxSkew.Duration,
+		MaxBodyBytes: conf.Server.MaxBodyBytes,
+	}
+}
+
+func accessLogSettings(conf *models.Config) middleware.AccessLogSettings {
+	fields := make([]string, 0)
+	for _, field := range strings.Split(conf.AccessLog.MaskFields, ",") {
+		if field = strings.TrimSpace(field); field != "" {
+			fields = append(fields, field)
+		}
+	}
+	return middleware.AccessLogSettings{
+		Enabled:      conf.AccessLog.Enabled,
+		SampleRate:   conf.AccessLog.SampleRate,
+		Routes:       conf.AccessLog.Routes,
+		LogBodies:    conf.AccessLog.LogBodies,
+		MaxBodyBytes: conf.AccessLog.MaxBodyBytes,
+		MaskFields:   fields,
+	}
+}
@@ -0,0 +1,84 @@
+package tracing
+
+import (
+	"context"
+	"fmt"
+	"os"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/exporters/stdout/stdouttrace"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// Exporters are the span exporters the tracing config can name.
+var Exporters = map[string]bool{"none": true, "stdout": true, "otlp": true}
+
+// Settings describe where spans go. SampleRatio is the share of new traces
+// recorded; traces started upstream follow the caller's decision.
+type Settings struct {
+	Exporter    string
+	Endpoint    string
+	Insecure    bool
+	SampleRatio float64
+	Service     string
+	Version     string
+}
+
+func init() {
+	otel.SetTextMapPropagator(propagation.NewCompositeTextMapPropagator(propagation.TraceContext{}, propagation.Baggage{}))
+}
+
+// Setup installs the global tracer provider described by settings. The
+// returned func flushes and stops it. With the none exporter spans are not
+// recorded, but incoming trace context is still passed on.
+func Setup(ctx context.Context, settings Settings) (func(context.Context) error, error) {
+	var exporter sdktrace.SpanExporter
+	var err error
+	switch settings.Exporter {
+	case "", "none":
+		return func(context.Context) error { return nil }, nil
+	case "stdout":
+		exporter, err = stdouttrace.New(stdouttrace.WithWriter(os.Stdout))
+	case "otlp":
+		options := []otlptracehttp.Option{otlptracehttp.WithEndpoint(settings.Endpoint)}
+		if settings.Insecure {
+			options = append(options, otlptracehttp.WithInsecure())
+		}
+		exporter, err = otlptracehttp.New(ctx, options...)
+	default:
+		return nil, fmt.Errorf("Exporter must be none, stdout or otlp, not %q!", settings.Exporter)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	provider := NewProvider(sdktrace.WithBatcher(exporter), settings)
+	otel.SetTracerProvider(provider)
+	return provider.Shutdown, nil
+}
+
+// NewProvider makes a tracer provider sending spans through processor, such
+// as one over an in-memory exporter in tests.
+func NewProvider(processor sdktrace.TracerProviderOption, settings Settings) *sdktrace.TracerProvider {
+	return sdktrace.NewTracerProvider(
+		processor,
+		sdktrace.WithSampler(sdktrace.ParentBased(sdktrace.TraceIDRatioBased(settings.SampleRatio))),
+		sdktrace.WithResource(resource.NewSchemaless(
+			attribute.String("service.name", settings.Service),
+			attribute.String("service.version", settings.Version),
+		)),
+	)
+}
+
+// Fail marks span as failed with err and returns err.
+func Fail(span trace.Span, err error) error {
+	span.RecordError(err)
+	span.SetStatus(codes.Error, err.Error())
+	return err
+}
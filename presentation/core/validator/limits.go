@@ -0,0 +1,54 @@
+package validator
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"job/domain/money"
+
+	"github.com/shopspring/decimal"
+)
+
+// Limits cap the amount of a single operation in the base currency. A zero
+// cap means no cap.
+type Limits struct {
+	Income   decimal.Decimal
+	Outcome  decimal.Decimal
+	Transfer decimal.Decimal
+}
+
+func NewLimits(income, outcome, transfer string) (*Limits, error) {
+	limits := new(Limits)
+	var err error
+	if limits.Income, err = parseLimit("MaxIncome", income); err != nil {
+		return nil, err
+	}
+	if limits.Outcome, err = parseLimit("MaxOutcome", outcome); err != nil {
+		return nil, err
+	}
+	if limits.Transfer, err = parseLimit("MaxTransfer", transfer); err != nil {
+		return nil, err
+	}
+	return limits, nil
+}
+
+func parseLimit(name, value string) (decimal.Decimal, error) {
+	if value == "" {
+		return decimal.Zero, nil
+	}
+	limit, err := decimal.NewFromString(value)
+	if err != nil || limit.IsNegative() {
+		return decimal.Zero, fmt.Errorf("%s must be a non-negative decimal, not %q!", name, value)
+	}
+	return limit, nil
+}
+
+func ValidateLimit(ctx context.Context, amount money.Money, limit decimal.Decimal) error {
+	if limit.IsPositive() && amount.Amount.GreaterThan(limit) {
+		errStr := "Amount must not exceed " + limit.String() + " " + amount.Currency + "!"
+		err := errors.New(errStr)
+		return err
+	}
+	return nil
+}
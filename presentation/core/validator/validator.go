@@ -3,10 +3,20 @@ package validator
 import (
 	"context"
 	"errors"
+	"fmt"
+	"strings"
+	"time"
 
-	"github.com/shopspring/decimal"
+	"job/domain/currency"
+	"job/domain/money"
 )
 
+// ErrNotEnoughMoney rejects an operation that would take a balance below zero.
+var ErrNotEnoughMoney = errors.New("Not enough money for transaction!")
+
+// MaxCurrencies limits how many display currencies one request may ask for.
+const MaxCurrencies = 10
+
 func ValidateId(ctx context.Context, id int64) error {
 	if id < 0 {
 		errStr := "Id must be positive integer!"
@@ -29,16 +39,121 @@ func ValidateIds(ctx context.Context, FromId, ToId int64) error {
 	return nil
 }
 
-func ValidateBalanceForTransaction(ctx context.Context, balance *decimal.Decimal, value string) error {
-	decimalValue := decimal.RequireFromString(value)
-	if !balance.GreaterThanOrEqual(decimalValue) {
-		errStr := "Not enough money for transaction!"
+func ValidateCurrency(ctx context.Context, code string) error {
+	if _, err := currency.Get(code); err != nil {
+		return err
+	}
+	return nil
+}
+
+// ValidateCurrencies checks a list of display currencies taken from a query.
+func ValidateCurrencies(ctx context.Context, codes []string) error {
+	if len(codes) > MaxCurrencies {
+		errStr := fmt.Sprintf("At most %d currencies can be requested at once!", MaxCurrencies)
+		err := errors.New(errStr)
+		return err
+	}
+	for _, code := range codes {
+		if err := ValidateCurrency(ctx, code); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func ValidateAmount(ctx context.Context, amount money.Money) error {
+	if amount.Currency != money.BaseCurrency() {
+		errStr := "Amount must be in " + money.BaseCurrency() + "!"
+		err := errors.New(errStr)
+		return err
+	}
+	if amount.IsZero() {
+		errStr := "Amount must be greater than zero!"
 		err := errors.New(errStr)
 		return err
 	}
 	return nil
 }
 
+// FieldError is a validation error of one field of a request body.
+type FieldError struct {
+	Field  string
+	Reason string
+}
+
+func (e *FieldError) Error() string {
+	return e.Reason
+}
+
+// ValidateQuote checks a quote request, returning a *FieldError naming
+// amount or currency.
+func ValidateQuote(ctx context.Context, amount money.Money, code string) error {
+	if _, err := currency.Get(code); err != nil {
+		return &FieldError{Field: "currency", Reason: err.Error()}
+	}
+	if amount.IsZero() {
+		return &FieldError{Field: "amount", Reason: "Amount must be greater than zero!"}
+	}
+	if strings.EqualFold(amount.Currency, code) {
+		return &FieldError{Field: "currency", Reason: "Currency must differ from amount currency!"}
+	}
+	if amount.Currency != money.BaseCurrency() && !strings.EqualFold(code, money.BaseCurrency()) {
+		return &FieldError{Field: "currency", Reason: "Quote must convert from or to " + money.BaseCurrency() + "!"}
+	}
+	return nil
+}
+
+func ValidateBalanceForTransaction(ctx context.Context, balance *money.Money, value money.Money) error {
+	if balance == nil {
+		errStr := "Balance has no amount!"
+		err := errors.New(errStr)
+		return err
+	}
+	if balance.Currency != value.Currency {
+		errStr := "Amount must be in balance currency " + balance.Currency + "!"
+		err := errors.New(errStr)
+		return err
+	}
+	if !balance.GreaterThanOrEqual(value) {
+		return ErrNotEnoughMoney
+	}
+	return nil
+}
+
+// ValidateDate accepts an empty value or a 2006-01-02 date that is not in the future.
+func ValidateDate(ctx context.Context, value string) error {
+	if value == "" {
+		return nil
+	}
+	date, err := time.Parse("2006-01-02", value)
+	if err != nil {
+		errStr := "Date must be in format 2006-01-02!"
+		err := errors.New(errStr)
+		return err
+	}
+	if date.After(time.Now()) {
+		errStr := "Date must not be in the future!"
+		err := errors.New(errStr)
+		return err
+	}
+	return nil
+}
+
+// SplitCurrencies turns "usd, EUR,usd" into [USD EUR].
+func SplitCurrencies(value string) []string {
+	codes := make([]string, 0)
+	seen := make(map[string]bool)
+	for _, code := range strings.Split(value, ",") {
+		code = strings.ToUpper(strings.TrimSpace(code))
+		if code == "" || seen[code] {
+			continue
+		}
+		seen[code] = true
+		codes = append(codes, code)
+	}
+	return codes
+}
+
 func ValidateQueryKey(s, value string) string {
 	if s == "" {
 		s = value